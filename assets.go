@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// staticDir is the directory holding the web ui templates and assets
+const staticDir = "static"
+
+// homeTemplate is the template of the web ui home page
+const homeTemplate = staticDir + "/home.tpl"
+
+type (
+
+	// staticAsset is a file served under /static, kept in memory with its gzip copy
+	staticAsset struct {
+		body        []byte
+		gzipped     []byte // nil when compression does not make the file smaller
+		contentType string
+		etag        string
+		gzipEtag    string // the gzip copy is a different representation with its own etag
+		immutable   bool   // requested by its content-hashed name
+	}
+
+	// assetSet holds the static assets keyed by request path
+	assetSet struct {
+		files    map[string]*staticAsset
+		manifest map[string]string // original name -> content-hashed name
+		ui       bool              // the home template is installed
+	}
+)
+
+// loadAssets reads every file of fsys, fingerprints it and pre-compresses it
+func loadAssets(fsys fs.FS) (*assetSet, error) {
+	set := &assetSet{files: map[string]*staticAsset{}, manifest: map[string]string{}}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) == ".tpl" { // templates are rendered, not served
+			return err
+		}
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])[:12]
+		ext := path.Ext(name)
+		hashed := strings.TrimSuffix(name, ext) + "." + hash + ext // app.js -> app.1a2b3c4d5e6f.js
+
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		gzipped, err := gzipAsset(body)
+		if err != nil {
+			return err
+		}
+
+		a := staticAsset{body: body, gzipped: gzipped, contentType: contentType, etag: `"` + hash + `"`, gzipEtag: `"` + hash + `-gz"`}
+		plain, fingerprinted := a, a
+		fingerprinted.immutable = true
+		set.files[name] = &plain
+		set.files[hashed] = &fingerprinted
+		set.manifest[name] = "/static/" + hashed
+		return nil
+	})
+	return set, err
+}
+
+// gzipAsset compresses body, returning nil when that does not save space
+func gzipAsset(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(body) {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// ServeHTTP serves an asset; fingerprinted names are cached for a year, the others are revalidated
+func (s *assetSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a, ok := s.files[strings.TrimPrefix(r.URL.Path, "/static/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	body, etag := a.body, a.etag
+	if a.gzipped != nil && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		h.Set("Content-Encoding", "gzip")
+		body, etag = a.gzipped, a.gzipEtag
+	}
+	h.Set("Content-Type", a.contentType)
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept-Encoding")
+	if a.immutable {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+	if etagMatch(r.Header.Get("If-None-Match"), etag) { // the client copy is current
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// loadStatic loads the static assets. A missing static directory means the web ui is not installed
+// and gives an empty set, while a directory without the home template is a broken install.
+func loadStatic() (*assetSet, error) {
+	if _, err := os.Stat(staticDir); errors.Is(err, fs.ErrNotExist) {
+		return &assetSet{files: map[string]*staticAsset{}, manifest: map[string]string{}}, nil
+	}
+	if _, err := os.Stat(homeTemplate); err != nil {
+		return nil, err
+	}
+	set, err := loadAssets(os.DirFS(staticDir))
+	if err != nil {
+		return nil, err
+	}
+	set.ui = true
+	return set, nil
+}
+
+// etagMatch reports whether the If-None-Match header lists etag, using the weak comparison
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// staticHandler serves the assets loaded by the assets lifecycle hook
+func staticHandler(w http.ResponseWriter, r *http.Request) {
+	assets.ServeHTTP(w, r)
+}
+
+func assetsHook() hook { // static assets lifecycle hook
+	return hook{
+		name: "static assets",
+		start: func(ctx context.Context) error {
+			set, err := loadStatic() // fingerprint and compress the assets once on startup
+			if err != nil {
+				return err
+			}
+			if !set.ui { // the directory is not tracked, serve the api only
+				log.Printf("%s not found, serving no web ui\n", staticDir)
+			}
+			assets = set
+			return nil
+		},
+	}
+}
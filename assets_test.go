@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestEtagMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{header: `"abc"`, want: true},
+		{header: `W/"abc"`, want: true},
+		{header: `"xyz", "abc"`, want: true},
+		{header: `"xyz",W/"abc"`, want: true},
+		{header: `*`, want: true},
+		{header: `"abc-gz"`},
+		{header: `"xyz"`},
+		{header: `abc`},
+		{header: ``},
+	}
+
+	for _, tt := range tests {
+		if got := etagMatch(tt.header, `"abc"`); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// constants used by the checks
+const (
+	checkTimeout      = 5 * time.Second // bounds the connection checks
+	namespaceNotFound = 26              // mongodb error code of a missing collection
+)
+
+// errSkipped is returned by the checks that do not apply
+var errSkipped = errors.New("skipped")
+
+// warning is returned by the checks that found something worth reporting that does not fail the check
+type warning string
+
+func (w warning) Error() string { return string(w) }
+
+// selfCheck is one step of the check subcommand
+type selfCheck struct {
+	name string
+	run  func() error
+}
+
+// runCheck validates the configuration and the dependencies, printing a pass/fail
+// report to out. It returns the process exit code: 0 when no check failed, warnings included, 1 otherwise.
+func runCheck(out io.Writer) int {
+	var s *mgo.Session
+	defer func() {
+		if s != nil {
+			s.Close()
+		}
+	}()
+
+	checks := []selfCheck{
+		{"config", checkConfig},
+		{"static assets", func() error {
+			set, err := loadStatic()
+			if err == nil && !set.ui { // api only, as on startup
+				return errSkipped
+			}
+			return err
+		}},
+		{"generic hooks config", checkHookSources},
+		{"mongodb connection", func() (err error) {
+			s, err = mgo.DialWithTimeout(hostName, checkTimeout)
+			if err != nil {
+				return err
+			}
+			return s.Ping()
+		}},
+		{"mongodb indexes", func() error {
+			return checkIndexes(s, collectionName, todoIndexes)
+		}},
+		{"mongodb import indexes", func() error {
+			return checkIndexes(s, importCollection, importIndexes)
+		}},
+		{"mongodb schema validator", func() error {
+			return checkValidator(s)
+		}},
+	}
+
+	failed := 0
+	for _, c := range checks {
+		err := c.run()
+		var warn warning
+		switch {
+		case err == errSkipped:
+			fmt.Fprintf(out, "SKIP  %s\n", c.name)
+		case errors.As(err, &warn):
+			fmt.Fprintf(out, "WARN  %s: %s\n", c.name, warn)
+		case err != nil:
+			failed++
+			fmt.Fprintf(out, "FAIL  %s: %s\n", c.name, err)
+		default:
+			fmt.Fprintf(out, "PASS  %s\n", c.name)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(out, "%d of %d checks failed\n", failed, len(checks))
+		return 1
+	}
+	fmt.Fprintln(out, "all checks passed")
+	return 0
+}
+
+// checkConfig validates the environment variables that are set; invalid values would otherwise fall back to the defaults silently
+func checkConfig() error {
+	problems := []string{}
+	validate := func(key string, ok func(string) bool) {
+		if v, set := os.LookupEnv(key); set && !ok(v) {
+			problems = append(problems, fmt.Sprintf("%s=%q", key, v))
+		}
+	}
+	isBool := func(v string) bool { _, err := strconv.ParseBool(v); return err == nil }
+	isPositive := func(v string) bool { n, err := strconv.Atoi(v); return err == nil && n > 0 }
+	isDuration := func(v string) bool { d, err := time.ParseDuration(v); return err == nil && d > 0 }
+
+	for _, key := range []string{"REUSEPORT", "MAINTENANCE_MODE", "STRICT_SCHEMA", "DEBUG", "LINK_PREVIEWS"} {
+		validate(key, isBool)
+	}
+	validate("MAX_PER_PAGE", isPositive)
+	validate("MAX_IMPORT_ROWS", isPositive)
+	validate("QUERY_TIMEOUT", isDuration)
+	validate("RESPONSE_ENVELOPE", func(v string) bool { return v == envelopeData || v == envelopeBare })
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid values: %s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// checkIndexes verifies the indexes of collection exist. Missing ones are only a warning since
+// they are created on startup, as on the first deploy to a fresh database.
+func checkIndexes(s *mgo.Session, collection string, want []mgo.Index) error {
+	if s == nil {
+		return errSkipped
+	}
+	indexes, err := s.DB(dbName).C(collection).Indexes()
+	if qerr, ok := err.(*mgo.QueryError); ok && qerr.Code == namespaceNotFound { // the collection does not exist yet
+		indexes, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+	for _, index := range indexes {
+		existing[strings.Join(index.Key, ",")] = true
+	}
+	missing := []string{}
+	for _, index := range want {
+		if key := strings.Join(index.Key, ","); !existing[key] {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return warning(fmt.Sprintf("missing indexes %s on %s, they are created on startup", strings.Join(missing, "; "), collection))
+	}
+	return nil
+}
+
+// checkValidator verifies the schema validator is installed when STRICT_SCHEMA is on
+func checkValidator(s *mgo.Session) error {
+	if s == nil || !envBool("STRICT_SCHEMA", false) {
+		return errSkipped
+	}
+	ok, err := hasValidator(s.DB(dbName))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("collection %s has no validator, it is installed on startup", collectionName)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// envBool reads a boolean from the environment, returning def when unset or invalid
+func envBool(key string, def bool) bool {
+	v, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envString reads a string from the environment, returning def when unset
+func envString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// envInt reads an integer from the environment, returning def when unset or invalid
+func envInt(key string, def int) int {
+	v, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envDuration reads a duration such as "5s" from the environment, returning def when unset or invalid
+func envDuration(key string, def time.Duration) time.Duration {
+	v, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// envPositiveInt reads an integer that must be positive, returning def with a warning when it is not
+func envPositiveInt(key string, def int) int {
+	v := envInt(key, def)
+	if v <= 0 {
+		log.Printf("%s must be a positive integer, using %d\n", key, def)
+		return def
+	}
+	return v
+}
+
+// envPositiveDuration reads a duration that must be positive, returning def with a warning when it is not
+func envPositiveDuration(key string, def time.Duration) time.Duration {
+	v := envDuration(key, def)
+	if v <= 0 {
+		log.Printf("%s must be a positive duration, using %s\n", key, def)
+		return def
+	}
+	return v
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/thedevsaddam/renderer"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// constants used by the generic webhook receiver
+const (
+	hookMaxBody         int64         = 1 << 20 // largest payload accepted
+	hookMaxTitle        int           = 500     // longest title created, in characters
+	hookSignatureHeader string        = "X-Signature"
+	hookTimestampHeader string        = "X-Timestamp"     // unix seconds, signed with the body
+	hookTolerance       time.Duration = 5 * time.Minute   // how far the timestamp may be from now
+	hookDeliveries      string        = "hook_deliveries" // signatures received within the tolerance window
+	defaultHookSource   string        = "default"
+)
+
+// hooksConfigFile is the json file mapping each source to its secret and todo template
+var hooksConfigFile = envString("GENERIC_HOOKS_CONFIG", "")
+
+// placeholderPattern matches the {$.path} placeholders of a title template
+var placeholderPattern = regexp.MustCompile(`\{(\$[^{}]*)\}`)
+
+// hookSource maps the payloads of one external source to todos, e.g.
+//
+//	{"ci": {"secret": "...", "title": "CI failed: {$.repository.name} on {$.branch}", "pinned": true}}
+type hookSource struct {
+	Secret string `json:"secret"` // key of the HMAC-SHA256 signature sent in X-Signature, see validSignature
+	Title  string `json:"title"`  // template filled with the {$.path} values of the payload
+	Pinned bool   `json:"pinned"` // pin the created todos
+}
+
+var hookSources map[string]hookSource // loaded by the generic hooks lifecycle hook
+
+// loadHookSources reads and validates the sources of the config file
+func loadHookSources(path string) (map[string]hookSource, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sources := map[string]hookSource{}
+	if err := json.Unmarshal(b, &sources); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for name, s := range sources {
+		if s.Secret == "" {
+			return nil, fmt.Errorf("%s: source %q has no secret", path, name)
+		}
+		if s.Title == "" {
+			return nil, fmt.Errorf("%s: source %q has no title template", path, name)
+		}
+		for _, m := range placeholderPattern.FindAllStringSubmatch(s.Title, -1) {
+			if _, err := parsePath(m[1]); err != nil {
+				return nil, fmt.Errorf("%s: source %q: %w", path, name, err)
+			}
+		}
+	}
+	return sources, nil
+}
+
+// parsePath splits a JSONPath style path such as $.alerts[0].labels.name into its steps;
+// object keys are strings and array indexes are ints
+func parsePath(path string) ([]interface{}, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path %q must start with $", path)
+	}
+
+	steps := []interface{}{}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("path %q has an empty key", path)
+			}
+			steps = append(steps, key)
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", path)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("path %q has an invalid index %q", path, rest[1:end])
+			}
+			steps = append(steps, i)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("path %q is invalid near %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// decodePayload decodes a json payload, keeping its numbers as sent so that 12345678 is not rendered as 1.2345678e+07
+func decodePayload(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var payload interface{}
+	if err := dec.Decode(&payload); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the json payload")
+	}
+	return payload, nil
+}
+
+// lookupPath returns the value at path in the payload decoded by decodePayload, as text
+func lookupPath(payload interface{}, path string) (string, bool) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return "", false
+	}
+
+	v := payload
+	for _, step := range steps {
+		switch s := step.(type) {
+		case string:
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if v, ok = obj[s]; !ok {
+				return "", false
+			}
+		case int:
+			arr, ok := v.([]interface{})
+			if !ok || s >= len(arr) {
+				return "", false
+			}
+			v = arr[s]
+		}
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case json.Number:
+		return val.String(), true
+	case bool:
+		return strconv.FormatBool(val), true
+	default: // objects and arrays are rendered as json
+		b, _ := json.Marshal(val)
+		return string(b), true
+	}
+}
+
+// validSignature checks the hex HMAC-SHA256 of "<timestamp>.<body>", sent as "sha256=<hex>".
+// Signing the timestamp and refusing those outside the tolerance bounds replays to that window,
+// within which receiveGenericHook accepts each signature once.
+func validSignature(secret string, body []byte, timestamp, signature string, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > hookTolerance || d < -hookTolerance {
+		return false
+	}
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+func receiveGenericHook(w http.ResponseWriter, r *http.Request) { // generic webhook handler
+	name := r.URL.Query().Get("source")
+	if name == "" {
+		name = defaultHookSource
+	}
+	source, ok := hookSources[name]
+	if !ok {
+		respondMessage(w, http.StatusNotFound, "Unknown hook source", nil)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, hookMaxBody))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+	timestamp, signature := r.Header.Get(hookTimestampHeader), r.Header.Get(hookSignatureHeader)
+	if !validSignature(source.Secret, body, timestamp, signature, time.Now()) { // reject unsigned, forged and old payloads
+		respondMessage(w, http.StatusUnauthorized, "Invalid signature", nil)
+		return
+	}
+	delivery := name + ":" + strings.ToLower(strings.TrimPrefix(signature, "sha256=")) // one spelling per signature
+	first, err := firstDelivery(r, delivery)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error recording delivery", err)
+		return
+	}
+	if !first { // a replay of a payload already received
+		respondMessage(w, http.StatusConflict, "Payload already received", nil)
+		return
+	}
+
+	payload, err := decodePayload(body)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	missing := []string{}
+	title := placeholderPattern.ReplaceAllStringFunc(source.Title, func(m string) string { // fill the template
+		path := m[1 : len(m)-1]
+		v, ok := lookupPath(payload, path)
+		if !ok {
+			missing = append(missing, path)
+		}
+		return v
+	})
+	title = truncateTitle(strings.TrimSpace(title), hookMaxTitle)
+	if len(missing) > 0 || title == "" {
+		respondMessage(w, http.StatusUnprocessableEntity, "Payload does not match the source mapping", renderer.M{
+			"missing": missing,
+		})
+		return
+	}
+
+	tm := todoModel{ // create a todo model
+		ID:        bson.NewObjectId(),
+		Title:     title,
+		Pinned:    source.Pinned,
+		CreatedAt: time.Now(),
+	}
+	err = timed(r, "insert", func() error { // insert the todo model to mongodb
+		return db.C(collectionName).Insert(&tm)
+	})
+	if err != nil {
+		db.C(hookDeliveries).RemoveId(delivery) // let the sender retry the same payload
+		respondError(w, http.StatusInternalServerError, "Error creating todo", err)
+		return
+	}
+	previews.lookup(r.Context(), tm.Title) // start fetching the link previews
+
+	respondMessage(w, http.StatusCreated, "Todo created successfully", renderer.M{
+		"todo_id": tm.ID.Hex(),
+	})
+}
+
+// truncateTitle cuts title to max characters, ending it with an ellipsis when it was cut
+func truncateTitle(title string, max int) string {
+	runes := []rune(title)
+	if len(runes) <= max {
+		return title
+	}
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// firstDelivery records a delivery and reports whether it is the first one with this id.
+// The records expire once the signature is outside the tolerance window and would be refused anyway.
+func firstDelivery(r *http.Request, id string) (bool, error) {
+	err := timed(r, "insert", func() error {
+		return db.C(hookDeliveries).Insert(bson.M{"_id": id, "received_at": time.Now()})
+	})
+	if mgo.IsDup(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func hooksHook() hook { // generic webhook sources lifecycle hook
+	return hook{
+		name: "generic hooks",
+		start: func(ctx context.Context) error {
+			if hooksConfigFile == "" { // no sources, every payload is refused
+				hookSources = map[string]hookSource{}
+				return nil
+			}
+			if _, on := maintenanceMessage(); !on { // the index is built on the first start outside maintenance
+				err := db.C(hookDeliveries).EnsureIndex(mgo.Index{
+					Key:         []string{"received_at"},
+					ExpireAfter: 2 * hookTolerance, // the timestamp may be ahead of the clock by the tolerance
+				})
+				if err != nil {
+					return err
+				}
+			}
+			sources, err := loadHookSources(hooksConfigFile)
+			if err != nil {
+				return err
+			}
+			hookSources = sources
+			return nil
+		},
+	}
+}
+
+// checkHookSources validates the generic hooks config file when one is set
+func checkHookSources() error {
+	if hooksConfigFile == "" {
+		return errSkipped
+	}
+	_, err := loadHookSources(hooksConfigFile)
+	return err
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []interface{}
+		wantErr bool
+	}{
+		{in: "$", want: []interface{}{}},
+		{in: "$.branch", want: []interface{}{"branch"}},
+		{in: "$.repository.name", want: []interface{}{"repository", "name"}},
+		{in: "$.alerts[0].labels.name", want: []interface{}{"alerts", 0, "labels", "name"}},
+		{in: "$[2][10]", want: []interface{}{2, 10}},
+		{in: "branch", wantErr: true},
+		{in: "$.", wantErr: true},
+		{in: "$..name", wantErr: true},
+		{in: "$.alerts[0", wantErr: true},
+		{in: "$.alerts[-1]", wantErr: true},
+		{in: "$.alerts[x]", wantErr: true},
+		{in: "$name", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePath(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePath(%q) = %v, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePath(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	payload, err := decodePayload([]byte(`{
+		"build": 12345678,
+		"ratio": 0.25,
+		"big": 1e21,
+		"ok": true,
+		"empty": null,
+		"repository": {"name": "todo"},
+		"alerts": [{"labels": {"name": "disk"}}],
+		"tags": ["a", "b"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{path: "$.build", want: "12345678", wantOK: true},
+		{path: "$.ratio", want: "0.25", wantOK: true},
+		{path: "$.big", want: "1e21", wantOK: true}, // rendered as sent
+		{path: "$.ok", want: "true", wantOK: true},
+		{path: "$.repository.name", want: "todo", wantOK: true},
+		{path: "$.alerts[0].labels.name", want: "disk", wantOK: true},
+		{path: "$.tags", want: `["a","b"]`, wantOK: true},
+		{path: "$.repository", want: `{"name":"todo"}`, wantOK: true},
+		{path: "$.empty"},
+		{path: "$.missing"},
+		{path: "$.alerts[1]"},
+		{path: "$.build.name"},
+		{path: "$.tags.name"},
+		{path: "invalid"},
+	}
+
+	for _, tt := range tests {
+		got, ok := lookupPath(payload, tt.path)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("lookupPath(%q) = %q, %v, want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestDecodePayloadTrailingData(t *testing.T) {
+	if _, err := decodePayload([]byte(`{"a": 1} {"b": 2}`)); err == nil {
+		t.Error("decodePayload accepted data after the payload")
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	now := time.Unix(1747051200, 0)
+	body := []byte(`{"branch":"main"}`)
+	sign := func(secret, timestamp string, body []byte) string {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "." + string(body)))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	old := strconv.FormatInt(now.Add(-hookTolerance-time.Second).Unix(), 10)
+	late := strconv.FormatInt(now.Add(-hookTolerance).Unix(), 10)
+	future := strconv.FormatInt(now.Add(hookTolerance+time.Second).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+		want      bool
+	}{
+		{name: "valid", body: body, timestamp: ts, signature: sign("s3cret", ts, body), want: true},
+		{name: "at the tolerance", body: body, timestamp: late, signature: sign("s3cret", late, body), want: true},
+		{name: "wrong secret", body: body, timestamp: ts, signature: sign("other", ts, body)},
+		{name: "changed body", body: []byte(`{"branch":"dev"}`), timestamp: ts, signature: sign("s3cret", ts, body)},
+		{name: "changed timestamp", body: body, timestamp: late, signature: sign("s3cret", ts, body)},
+		{name: "replayed", body: body, timestamp: old, signature: sign("s3cret", old, body)},
+		{name: "from the future", body: body, timestamp: future, signature: sign("s3cret", future, body)},
+		{name: "no timestamp", body: body, timestamp: "", signature: sign("s3cret", "", body)},
+		{name: "no signature", body: body, timestamp: ts, signature: ""},
+		{name: "not hex", body: body, timestamp: ts, signature: "sha256=zz"},
+	}
+
+	for _, tt := range tests {
+		if got := validSignature("s3cret", tt.body, tt.timestamp, tt.signature, now); got != tt.want {
+			t.Errorf("%s: validSignature = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTruncateTitle(t *testing.T) {
+	tests := []struct {
+		in   string
+		max  int
+		want string
+	}{
+		{in: "disk full", max: 9, want: "disk full"},
+		{in: "disk full", max: 20, want: "disk full"},
+		{in: "disk full on db-1", max: 10, want: "disk full…"},
+		{in: "ดิสก์เต็มแล้ว", max: 5, want: "ดิสก…"}, // cut on characters, not bytes
+	}
+
+	for _, tt := range tests {
+		if got := truncateTitle(tt.in, tt.max); got != tt.want {
+			t.Errorf("truncateTitle(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
+	"github.com/thedevsaddam/renderer"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// constants used by the imports
+const (
+	importCollection   string        = "imports"       // import jobs shared by every replica
+	importQueueSize    int           = 16              // imports waiting for a worker
+	importMaxBody      int64         = 10 << 20        // largest import file
+	importRetention    time.Duration = 24 * time.Hour  // finished imports are removed after this
+	importPollInterval time.Duration = 2 * time.Second // how often an idle worker looks for queued imports
+	importStaleAfter   time.Duration = time.Minute     // a running import without progress for this long is taken over
+)
+
+// maxImportRows is the largest number of rows accepted in one import
+var maxImportRows = envPositiveInt("MAX_IMPORT_ROWS", 10000)
+
+// import job statuses
+const (
+	importQueued  = "queued"
+	importRunning = "running"
+	importDone    = "done"
+)
+
+// importIndexes are the indexes of the import collection
+var importIndexes = []mgo.Index{
+	{Key: []string{"status", "created_at"}},                      // claim the oldest queued import
+	{Key: []string{"finished_at"}, ExpireAfter: importRetention}, // remove the finished imports
+}
+
+type (
+
+	// importRow is one todo to import
+	importRow struct {
+		Title     string `bson:"title" json:"title"`
+		Completed bool   `bson:"completed" json:"completed"`
+		Pinned    bool   `bson:"pinned" json:"pinned"`
+	}
+
+	// importError is a row that could not be imported
+	importError struct {
+		Row   int    `bson:"row" json:"row"` // starting at 1, not counting the csv header
+		Title string `bson:"title" json:"title"`
+		Error string `bson:"error" json:"error"`
+	}
+
+	// importJob is an import stored in the import collection until a worker processes it
+	importJob struct {
+		ID          bson.ObjectId `bson:"_id" json:"id"`
+		Status      string        `bson:"status" json:"status"`
+		Total       int           `bson:"total" json:"total"`
+		Processed   int           `bson:"processed" json:"processed"`
+		Imported    int           `bson:"imported" json:"imported"`
+		Failed      int           `bson:"failed" json:"failed"`
+		Errors      []importError `bson:"errors" json:"errors"`
+		CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+		FinishedAt  *time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+		RequestID   string        `bson:"request_id,omitempty" json:"-"`   // request id of the upload
+		Worker      string        `bson:"worker,omitempty" json:"-"`       // worker running the import
+		HeartbeatAt *time.Time    `bson:"heartbeat_at,omitempty" json:"-"` // last progress of the worker
+		Rows        []importRow   `bson:"rows,omitempty" json:"-"`         // removed once processed
+	}
+
+	// importWorker runs the imports claimed from the import collection one at a time
+	importWorker struct {
+		id   string        // marks the imports claimed by this process
+		wake chan struct{} // signalled when an import is queued by this process
+	}
+)
+
+var imports = &importWorker{id: bson.NewObjectId().Hex(), wake: make(chan struct{}, 1)} // import worker
+
+// parseImport reads the rows of a json array or of a csv file with a title header
+func parseImport(w http.ResponseWriter, r *http.Request) ([]importRow, error) {
+	body := http.MaxBytesReader(w, r.Body, importMaxBody)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	rows := []importRow{}
+	if mediaType != "text/csv" {
+		if err := json.NewDecoder(body).Decode(&rows); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+
+	records, err := csv.NewReader(body).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	columns := map[string]int{}
+	for i, name := range records[0] { // the header names the columns
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	titleCol, ok := columns["title"]
+	if !ok {
+		return nil, errors.New("csv header has no title column")
+	}
+	field := func(rec []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(rec) {
+			return strings.TrimSpace(rec[i])
+		}
+		return ""
+	}
+	for _, rec := range records[1:] {
+		row := importRow{}
+		if titleCol < len(rec) {
+			row.Title = strings.TrimSpace(rec[titleCol])
+		}
+		row.Completed, _ = strconv.ParseBool(field(rec, "completed")) // empty or invalid means false
+		row.Pinned, _ = strconv.ParseBool(field(rec, "pinned"))
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func createImport(w http.ResponseWriter, r *http.Request) { // create import handler
+	rows, err := parseImport(w, r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid import file", err)
+		return
+	}
+	if len(rows) == 0 || len(rows) > maxImportRows {
+		respondMessage(w, http.StatusBadRequest, fmt.Sprintf("An import must have between 1 and %d rows", maxImportRows), nil)
+		return
+	}
+
+	var queued int
+	err = timed(r, "count", func() (err error) {
+		queued, err = count(importCollection, bson.M{"status": importQueued})
+		return err
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error queuing import", err)
+		return
+	}
+	if queued >= importQueueSize {
+		w.Header().Set("Retry-After", "60")
+		respondMessage(w, http.StatusServiceUnavailable, "Too many imports in progress, please try again later", nil)
+		return
+	}
+
+	job := importJob{
+		ID:        bson.NewObjectId(),
+		Status:    importQueued,
+		Total:     len(rows),
+		Errors:    []importError{},
+		CreatedAt: time.Now(),
+		RequestID: middleware.GetReqID(r.Context()),
+		Rows:      rows,
+	}
+	if err := timed(r, "insert", func() error { return db.C(importCollection).Insert(&job) }); err != nil {
+		respondError(w, http.StatusInternalServerError, "Error queuing import", err)
+		return
+	}
+	imports.notify()
+
+	respondMessage(w, http.StatusAccepted, "Import queued", renderer.M{
+		"import_id": job.ID.Hex(),
+	})
+}
+
+// findImport loads the import named in the url, without its rows
+func findImport(r *http.Request) (importJob, error) {
+	job := importJob{}
+	id := strings.TrimSpace(chi.URLParam(r, "id"))
+	if !bson.IsObjectIdHex(id) {
+		return job, mgo.ErrNotFound
+	}
+	err := timed(r, "find", func() error {
+		return db.C(importCollection).FindId(bson.ObjectIdHex(id)).Select(bson.M{"rows": 0}).One(&job)
+	})
+	return job, err
+}
+
+func fetchImport(w http.ResponseWriter, r *http.Request) { // import status handler
+	job, err := findImport(r)
+	if err == mgo.ErrNotFound {
+		respondMessage(w, http.StatusNotFound, "Import not found", nil)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error fetching import", err)
+		return
+	}
+
+	respondData(w, r, http.StatusOK, job, nil)
+}
+
+func fetchImportErrors(w http.ResponseWriter, r *http.Request) { // import error report handler
+	job, err := findImport(r)
+	if err == mgo.ErrNotFound {
+		respondMessage(w, http.StatusNotFound, "Import not found", nil)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error fetching import", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", `attachment; filename="import-`+job.ID.Hex()+`-errors.csv"`)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"row", "title", "error"})
+	for _, e := range job.Errors {
+		cw.Write([]string{strconv.Itoa(e.Row), e.Title, e.Error})
+	}
+	cw.Flush()
+}
+
+// notify wakes the worker up without waiting for the next poll
+func (wk *importWorker) notify() {
+	select {
+	case wk.wake <- struct{}{}:
+	default: // a wake up is already pending
+	}
+}
+
+// claim marks the oldest queued import, or one whose worker stopped making progress, as running here.
+// It returns nil when there is nothing to import.
+func (wk *importWorker) claim(now time.Time) (*importJob, error) {
+	job := &importJob{}
+	claimable := bson.M{"$or": []bson.M{
+		{"status": importQueued},
+		{"status": importRunning, "heartbeat_at": bson.M{"$lt": now.Add(-importStaleAfter)}},
+	}}
+	err := timed(nil, "update", func() error {
+		_, err := db.C(importCollection).Find(claimable).Sort("created_at").Apply(mgo.Change{
+			Update:    bson.M{"$set": bson.M{"status": importRunning, "worker": wk.id, "heartbeat_at": now}},
+			ReturnNew: true,
+		}, job)
+		return err
+	})
+	if err == mgo.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// work runs the claimable imports until there are none left or done is closed.
+// Nothing is claimed in maintenance mode; the queued imports wait for it to end.
+func (wk *importWorker) work(done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if _, on := maintenanceMessage(); on {
+			return
+		}
+
+		job, err := wk.claim(time.Now())
+		if err != nil {
+			log.Printf("claim import: %s\n", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		if err := wk.run(job, done); err != nil { // left running, another worker takes it over once stale
+			log.Printf("import %s: %s\n", job.ID.Hex(), err)
+		}
+	}
+}
+
+// run imports the rows of job one by one from where the last worker stopped, saving the progress after
+// each row. A worker crashing between a row and its progress may see that row imported twice.
+func (wk *importWorker) run(job *importJob, done <-chan struct{}) error {
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, job.RequestID)
+	log.Printf("%simport %s started at row %d of %d\n", logPrefix(ctx), job.ID.Hex(), job.Processed+1, len(job.Rows))
+	mine := bson.M{"_id": job.ID, "worker": wk.id} // fails once another worker took the import over
+
+	for i := job.Processed; i < len(job.Rows); i++ {
+		select {
+		case <-done: // shutting down, hand the rest of the rows over to the next worker
+			return wk.release(ctx, job, mine)
+		default:
+		}
+		if _, on := maintenanceMessage(); on { // pause until maintenance ends
+			return wk.release(ctx, job, mine)
+		}
+
+		row := job.Rows[i]
+		err := errors.New("title is required")
+		if strings.TrimSpace(row.Title) != "" {
+			tm := todoModel{
+				ID:        bson.NewObjectId(),
+				Title:     strings.TrimSpace(row.Title),
+				Completed: row.Completed,
+				Pinned:    row.Pinned,
+				CreatedAt: time.Now(),
+			}
+			if tm.Completed {
+				tm.CompletedAt = &tm.CreatedAt
+			}
+			err = timed(nil, "insert", func() error {
+				return db.C(collectionName).Insert(&tm)
+			})
+		}
+
+		progress := bson.M{
+			"$inc": bson.M{"processed": 1, "imported": 1},
+			"$set": bson.M{"heartbeat_at": time.Now()},
+		}
+		if err != nil {
+			progress["$inc"] = bson.M{"processed": 1, "failed": 1}
+			progress["$push"] = bson.M{"errors": importError{Row: i + 1, Title: row.Title, Error: err.Error()}}
+		}
+		err = timed(nil, "update", func() error { return db.C(importCollection).Update(mine, progress) })
+		if err == mgo.ErrNotFound {
+			log.Printf("%simport %s taken over by another worker\n", logPrefix(ctx), job.ID.Hex())
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	err := timed(nil, "update", func() error {
+		return db.C(importCollection).Update(mine, bson.M{
+			"$set":   bson.M{"status": importDone, "finished_at": now},
+			"$unset": bson.M{"rows": "", "worker": "", "heartbeat_at": ""},
+		})
+	})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	log.Printf("%simport %s done\n", logPrefix(ctx), job.ID.Hex())
+	return nil
+}
+
+// release puts a claimed import back in the queue so that any worker can resume it
+func (wk *importWorker) release(ctx context.Context, job *importJob, mine bson.M) error {
+	err := timed(nil, "update", func() error {
+		return db.C(importCollection).Update(mine, bson.M{
+			"$set":   bson.M{"status": importQueued},
+			"$unset": bson.M{"worker": "", "heartbeat_at": ""},
+		})
+	})
+	if err != nil && err != mgo.ErrNotFound {
+		return err
+	}
+	log.Printf("%simport %s released\n", logPrefix(ctx), job.ID.Hex())
+	return nil
+}
+
+func importHook() hook { // import worker lifecycle hook
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	return hook{
+		name:    "import worker",
+		timeout: 30 * time.Second,
+		start: func(ctx context.Context) error {
+			if _, on := maintenanceMessage(); !on { // the indexes are built on the first start outside maintenance
+				for _, index := range importIndexes {
+					if err := db.C(importCollection).EnsureIndex(index); err != nil {
+						return err
+					}
+				}
+			}
+			go func() {
+				defer close(stopped)
+				ticker := time.NewTicker(importPollInterval)
+				defer ticker.Stop()
+				for {
+					imports.work(done)
+					select {
+					case <-imports.wake:
+					case <-ticker.C:
+					case <-done:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			close(done)
+			<-stopped // wait for the running import to be released between two rows
+			return nil
+		},
+	}
+}
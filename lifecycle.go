@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// defaultHookTimeout is used when a hook does not set its own timeout
+const defaultHookTimeout = 5 * time.Second
+
+type (
+
+	// hook is a subsystem registered with the lifecycle manager
+	hook struct {
+		name    string                          // name used in log and error messages
+		start   func(ctx context.Context) error // called on startup, must not block
+		stop    func(ctx context.Context) error // called on shutdown
+		timeout time.Duration                   // deadline for each of start and stop
+	}
+
+	// lifecycle starts hooks in registration order and stops them in reverse
+	lifecycle struct {
+		hooks   []hook
+		started int // number of hooks started successfully
+	}
+)
+
+// register adds a hook to the lifecycle
+func (l *lifecycle) register(h hook) {
+	l.hooks = append(l.hooks, h)
+}
+
+// start runs the start hooks in order; on failure the hooks already started are stopped
+func (l *lifecycle) start() error {
+	for _, h := range l.hooks {
+		log.Println("Starting", h.name)
+		if err := h.call(h.start); err != nil { // start the subsystem
+			l.stop()
+			return fmt.Errorf("start %s: %w", h.name, err)
+		}
+		l.started++
+	}
+	return nil
+}
+
+// stop runs the stop hooks of the started subsystems in reverse order
+func (l *lifecycle) stop() error {
+	var firstErr error
+	for ; l.started > 0; l.started-- {
+		h := l.hooks[l.started-1]
+		log.Println("Stopping", h.name)
+		if err := h.call(h.stop); err != nil { // keep stopping the others on error
+			log.Printf("stop %s: %s\n", h.name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("stop %s: %w", h.name, err)
+			}
+		}
+	}
+	return firstErr
+}
+
+// run starts every hook, waits for one of the signals and stops every hook
+func (l *lifecycle) run(signals ...os.Signal) error {
+	stopChan := make(chan os.Signal, 1) // channel to receive the os signals
+	signal.Notify(stopChan, signals...) // notify the channel when one of the signals is received
+	defer signal.Stop(stopChan)
+
+	if err := l.start(); err != nil {
+		return err
+	}
+
+	sig := <-stopChan // wait for a signal
+	log.Printf("Received %s, shutting down...\n", sig)
+	return l.stop()
+}
+
+// call runs fn with the hook timeout
+func (h hook) call(fn func(ctx context.Context) error) error {
+	if fn == nil {
+		return nil
+	}
+
+	timeout := h.timeout
+	if timeout <= 0 {
+		timeout = defaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- fn(ctx) }()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done(): // the hook did not finish in time
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// server side limits, set by the operator so a single client cannot exhaust the server
+var (
+	maxPerPage   = envPositiveInt("MAX_PER_PAGE", 100)                 // largest page of todos returned at once
+	queryTimeout = envPositiveDuration("QUERY_TIMEOUT", 5*time.Second) // maxTimeMS of every query
+)
+
+// maxSkip is the largest number of items skipped to reach a page
+const maxSkip = math.MaxInt32
+
+// page is the page of a list requested with ?page= and ?per_page=
+type page struct {
+	number  int // starting at 1
+	perPage int
+}
+
+// parsePage reads the page parameters; per_page defaults to, and is capped at, maxPerPage
+func parsePage(r *http.Request) (page, error) {
+	p := page{number: 1, perPage: maxPerPage}
+	for name, into := range map[string]*int{"page": &p.number, "per_page": &p.perPage} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return p, fmt.Errorf("%s must be a positive integer", name)
+		}
+		*into = n
+	}
+	if p.perPage > maxPerPage {
+		p.perPage = maxPerPage
+	}
+	if p.number-1 > maxSkip/p.perPage { // (page-1)*per_page would overflow
+		return p, fmt.Errorf("page must be at most %d", maxSkip/p.perPage+1)
+	}
+	return p, nil
+}
+
+// skip returns the number of items before the page
+func (p page) skip() int {
+	return (p.number - 1) * p.perPage
+}
+
+// count counts the documents of collection matching query within queryTimeout.
+// Query.Count drops SetMaxTime, so the count command is run with maxTimeMS directly.
+func count(collection string, query bson.M) (int, error) {
+	var result struct {
+		N int `bson:"n"`
+	}
+	err := db.Run(bson.D{
+		{Name: "count", Value: collection},
+		{Name: "query", Value: query},
+		{Name: "maxTimeMS", Value: int64(queryTimeout / time.Millisecond)},
+	}, &result)
+	return result.N, err
+}
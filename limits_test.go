@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    page
+		wantErr bool
+	}{
+		{query: "", want: page{number: 1, perPage: maxPerPage}},
+		{query: "page=3&per_page=10", want: page{number: 3, perPage: 10}},
+		{query: "per_page=100000", want: page{number: 1, perPage: maxPerPage}}, // capped
+		{query: "page=0", wantErr: true},
+		{query: "per_page=-1", wantErr: true},
+		{query: "page=x", wantErr: true},
+		{query: "page=9223372036854775807", wantErr: true}, // skip would overflow
+	}
+
+	for _, tt := range tests {
+		got, err := parsePage(httptest.NewRequest("GET", "/todo?"+tt.query, nil))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parsePage(%q) = %+v, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parsePage(%q) = %+v, %v, want %+v", tt.query, got, err, tt.want)
+		}
+		if got.skip() < 0 {
+			t.Errorf("parsePage(%q).skip() = %d, want >= 0", tt.query, got.skip())
+		}
+	}
+}
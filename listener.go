@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket activation
+const listenFdsStart = 3
+
+// errReusePortUnsupported is returned by reusePort on the platforms without SO_REUSEPORT
+var errReusePortUnsupported = errors.New("SO_REUSEPORT is not supported on this platform")
+
+// listen opens the server socket. A socket inherited through systemd socket
+// activation (LISTEN_FDS) is used when present, so the listening socket stays
+// open while the process restarts. Otherwise REUSEPORT=true binds with
+// SO_REUSEPORT, letting the new process bind before the old one has drained;
+// where the platform lacks it a warning is logged and the port is bound plainly.
+func listen(addr string) (net.Listener, error) {
+	if ln, err := inheritedListener(); ln != nil || err != nil {
+		return ln, err
+	}
+
+	lc := net.ListenConfig{}
+	if envBool("REUSEPORT", false) {
+		lc.Control = reusePort // set SO_REUSEPORT before binding
+	}
+	ln, err := lc.Listen(context.Background(), "tcp", addr)
+	if errors.Is(err, errReusePortUnsupported) {
+		log.Printf("REUSEPORT ignored: %s\n", err)
+		return net.Listen("tcp", addr)
+	}
+	return ln, err
+}
+
+// inheritedListener returns the socket passed by systemd, or nil when there is none
+func inheritedListener() (net.Listener, error) {
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) { // the fds are meant for another process
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+
+	f := os.NewFile(listenFdsStart, "LISTEN_FD_3")
+	defer f.Close() // net.FileListener duplicates the fd
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited socket: %w", err)
+	}
+	os.Unsetenv("LISTEN_PID") // do not pass the socket on to child processes
+	os.Unsetenv("LISTEN_FDS")
+	return ln, nil
+}
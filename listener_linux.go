@@ -0,0 +1,7 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+// +build linux,!mips,!mipsle,!mips64,!mips64le
+
+package main
+
+// soReusePort is SO_REUSEPORT, which the syscall package does not define for every linux port
+const soReusePort = 0xf
@@ -0,0 +1,11 @@
+//go:build (!linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd) || mips || mipsle || mips64 || mips64le
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd mips mipsle mips64 mips64le
+
+package main
+
+import "syscall"
+
+// reusePort is not supported on this platform
+func reusePort(network, address string, c syscall.RawConn) error {
+	return errReusePortUnsupported
+}
@@ -3,20 +3,26 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/go-chi/chi"
+	"github.com/go-chi/chi/middleware"
 	"github.com/thedevsaddam/renderer"
 	mgo "gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
 var rnd *renderer.Render // renderer instance
+var sess *mgo.Session    // mongodb session, opened by the mongodb lifecycle hook
 var db *mgo.Database     // mongodb database instance
+var assets *assetSet     // static assets, loaded by the static assets lifecycle hook
 
 // constants used in the application
 const (
@@ -24,172 +30,380 @@ const (
 	port           string = ":9000"
 	dbName         string = "demo_todo"
 	collectionName string = "todo"
+	maxMergeIDs    int    = 50 // largest number of todos merged at once
 )
 
 type (
 
 	// TodoModel struct is used to store the todo data in mongodb
 	todoModel struct {
-		ID        bson.ObjectId `bson:"_id,omitempty"`
-		Title     string        `bson:"title"`
-		Completed bool          `bson:"completed"`
-		CreatedAt time.Time     `bson:"created_at"`
+		ID           bson.ObjectId `bson:"_id,omitempty"`
+		Title        string        `bson:"title"`
+		Completed    bool          `bson:"completed"`
+		CompletedAt  *time.Time    `bson:"completed_at,omitempty"`
+		Pinned       bool          `bson:"pinned"`
+		SnoozedUntil *time.Time    `bson:"snoozed_until,omitempty"`
+		CreatedAt    time.Time     `bson:"created_at"`
 	}
 
 	// Todo struct is used to render the todo data
 	todo struct {
-		ID        string    `json:"id"`
-		Title     string    `json:"title"`
-		Completed bool      `json:"completed"`
-		CreatedAt time.Time `json:"created_at"`
+		ID           string         `json:"id"`
+		Title        string         `json:"title"`
+		Completed    bool           `json:"completed"`
+		CompletedAt  *time.Time     `json:"completed_at,omitempty"`
+		Pinned       bool           `json:"pinned"`
+		SnoozedUntil *time.Time     `json:"snoozed_until,omitempty"`
+		CreatedAt    time.Time      `json:"created_at"`
+		Previews     []linkPreview  `json:"previews,omitempty"`
+		Relative     *relativeDates `json:"relative,omitempty"` // set with ?relative_dates=true
 	}
 )
 
 func init() {
-	rnd = renderer.New()              // initialize the renderer
-	sess, err := mgo.Dial(hostName)   // connect to mongodb
-	checkErr(err)                     // check for error
-	sess.SetMode(mgo.Monotonic, true) // set the session mode to monotonic
-	db = sess.DB(dbName)              // get the database
+	rnd = renderer.New() // initialize the renderer
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request) { // home handler
-	err := rnd.Template(w, http.StatusOK, []string{"static/home.tpl"}, nil) // render the home template
-	checkErr(err)                                                          // check for error
+	if !assets.ui { // check if the web ui is installed
+		respondMessage(w, http.StatusNotFound, "Web ui not installed", nil)
+		return
+	}
+	err := rnd.Template(w, http.StatusOK, []string{homeTemplate}, renderer.M{
+		"assets": assets.manifest, // fingerprinted asset urls, e.g. {{index .assets "app.js"}}
+	}) // render the home template
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error rendering home page", err)
+	}
 }
 
 func fetchTodos(w http.ResponseWriter, r *http.Request) { // fetch todos handler
 	todos := []todoModel{} // initialize the todos slice
+	query := bson.M{}      // filter built from the query string
 
-	if err := db.C(collectionName).Find(bson.M{}).All(&todos); err != nil { // fetch all the todos from mongodb
-		rnd.JSON(w, http.StatusProcessing, renderer.M{
-			"message": "Error fetching todos",
-			"error":   err,
+	pg, err := parsePage(r) // get the requested page, capped at the maximum page size
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid page", err)
+		return
+	}
 
-		})
-		return 
-}
-todoList := []todo{} // initialize the todo list
+	h, err := newHumanizer(r) // humanize the dates when asked to
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid relative dates options", err)
+		return
+	}
+
+	if p := r.URL.Query().Get("pinned"); p != "" { // filter on the pinned status
+		pinned, err := strconv.ParseBool(p)
+		if err != nil {
+			respondMessage(w, http.StatusBadRequest, "Invalid pinned filter", nil)
+			return
+		}
+		query["pinned"] = true
+		if !pinned {
+			query["pinned"] = bson.M{"$ne": true} // todos created before pinning have no pinned field
+		}
+	}
 
-for _, t := range todos { // loop through the todos
-	todoList = append(todoList, todo{ // append the todo to the todo list
-		ID:        t.ID.Hex(), // convert the object id to hex
-		Title:     t.Title,    // set the title
-		Completed: t.Completed, // set the completed status
-		CreatedAt: t.CreatedAt, // set the created at
+	now := time.Now()
+	switch r.URL.Query().Get("snoozed") { // snoozed todos are hidden until they wake up
+	case "", "false":
+		for k, v := range notSnoozed(now) {
+			query[k] = v
+		}
+	case "true":
+		query["snoozed_until"] = bson.M{"$gt": now}
+	case "all": // no filter
+	default:
+		respondMessage(w, http.StatusBadRequest, "Invalid snoozed filter", nil)
+		return
+	}
+
+	err = timed(r, "find", func() error { // fetch the todos from mongodb, pinned first
+		return db.C(collectionName).Find(query).Sort("-pinned", "created_at").
+			Skip(pg.skip()).Limit(pg.perPage + 1). // one more to know if there is a next page
+			SetMaxTime(queryTimeout).All(&todos)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error fetching todos", err)
+		return
+	}
+
+	hasMore := len(todos) > pg.perPage
+	if hasMore {
+		todos = todos[:pg.perPage]
+		next := *r.URL
+		q := next.Query()
+		q.Set("page", strconv.Itoa(pg.number+1))
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", "<"+next.String()+`>; rel="next"`) // bare responses carry no meta
+	}
+
+	todoList := toTodos(r.Context(), todos)
+	h.addRelativeDates(todoList)
+
+	respondData(w, r, http.StatusOK, todoList, renderer.M{ // return the todo list
+		"page":     pg.number,
+		"per_page": pg.perPage,
+		"has_more": hasMore,
 	})
 }
 
-rnd.JSON(w, http.StatusOK, renderer.M{
-	"data": todoList, // set the todo list
-})
+func toTodos(ctx context.Context, todos []todoModel) []todo { // convert the todo models to the rendered todos
+	todoList := []todo{} // initialize the todo list
+
+	for _, t := range todos { // loop through the todos
+		todoList = append(todoList, todo{ // append the todo to the todo list
+			ID:           t.ID.Hex(),                    // convert the object id to hex
+			Title:        t.Title,                       // set the title
+			Completed:    t.Completed,                   // set the completed status
+			CompletedAt:  t.CompletedAt,                 // set the completed at
+			Pinned:       t.Pinned,                      // set the pinned status
+			SnoozedUntil: t.SnoozedUntil,                // set the snoozed until
+			CreatedAt:    t.CreatedAt,                   // set the created at
+			Previews:     previews.lookup(ctx, t.Title), // set the link previews fetched so far
+		})
+	}
+	return todoList
 }
 
 func createTodo(w http.ResponseWriter, r *http.Request) { // create todo handler
 	var t todo
 
 	if err := json.NewDecoder(r.Body).Decode(&t); err != nil { // decode the request body to todo struct
-		rnd.JSON(w, http.StatusProcessing, err)
+		respondError(w, http.StatusBadRequest, "Invalid request body", err)
 		return
-}
+	}
 
-if t.Title == "" { // check if the title is empty
-	rnd.JSON(w, http.StatusBadRequest, renderer.M{
-		"message": "Title is required",
-	})
-	return
-}
+	if t.Title == "" { // check if the title is empty
+		respondMessage(w, http.StatusBadRequest, "Title is required", nil)
+		return
+	}
 
-tm := todoModel{ // create a todo model
-	ID:        bson.NewObjectId(), // generate a new object id
-	Title:     t.Title,            // set the title
-	Completed: false,              // set the completed status
-	CreatedAt: time.Now(),         // set the created at
-}
+	tm := todoModel{ // create a todo model
+		ID:        bson.NewObjectId(), // generate a new object id
+		Title:     t.Title,            // set the title
+		Completed: false,              // set the completed status
+		CreatedAt: time.Now(),         // set the created at
+	}
 
-if err := db.C(collectionName).Insert(&tm); err != nil { // insert the todo model to mongodb
-	rnd.JSON(w, http.StatusProcessing, renderer.M{
-		"message": "Error creating todo",
-		"error":   err,
-	})	
-	return
-}
+	err := timed(r, "insert", func() error { // insert the todo model to mongodb
+		return db.C(collectionName).Insert(&tm)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error creating todo", err)
+		return
+	}
+
+	previews.lookup(r.Context(), tm.Title) // start fetching the link previews
 
-rnd.JSON(w, http.StatusCreated, renderer.M{// return the created todo model
-	"message": "Todo created successfully",
-	"todo_id": tm.ID.Hex()
-})
+	respondMessage(w, http.StatusCreated, "Todo created successfully", renderer.M{ // return the created todo id
+		"todo_id": tm.ID.Hex(),
+	})
 }
 
 func deleteTodo(w http.ResponseWriter, r *http.Request) { // delete todo handler
 	id := strings.TrimSpace(chi.URLParam(r, "id")) // get the todo id from the url
 
 	if !bson.IsObjectIdHex(id) { // check if the todo id is valid
-		rnd.JSON(w, http.StatusBadRequest, renderer.M{
-			"message": "Invalid todo id",
-		})
+		respondMessage(w, http.StatusBadRequest, "Invalid todo id", nil)
 		return
 	}
 
-
-	if err := db.C(collectionName).RemoveId(bson.ObjectIdHex(todoID)); err != nil { // delete the todo from mongodb
-		rnd.JSON(w, http.StatusProcessing, renderer.M{
-			"message": "Error deleting todo",
-			"error":   err,
-		})
+	err := timed(r, "remove", func() error { // delete the todo from mongodb
+		return db.C(collectionName).RemoveId(bson.ObjectIdHex(id))
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error deleting todo", err)
 		return
 	}
 
-	rnd.JSON(w, http.StatusOK, renderer.M{
-		"message": "Todo deleted successfully",
-	})
+	respondMessage(w, http.StatusOK, "Todo deleted successfully", nil)
 }
 
 func updateTodo(w http.ResponseWriter, r *http.Request) { // update todo handler
 	id := strings.TrimSpace(chi.URLParam(r, "id")) // get the todo id from the url
 
 	if !bson.IsObjectIdHex(id) { // check if the todo id is valid
-		rnd.JSON(w, http.StatusBadRequest, renderer.M{
-			"message": "Invalid todo id",
-		})
+		respondMessage(w, http.StatusBadRequest, "Invalid todo id", nil)
 		return
+	}
+
+	var t todo
+
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil { // decode the request body to todo struct
+		respondError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	if t.Title == "" { // check if the title is empty
+		respondMessage(w, http.StatusBadRequest, "Title is required", nil)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"title": t.Title, "completed": t.Completed}} // update, keeping the other fields
+	if !t.Completed {
+		update["$unset"] = bson.M{"completed_at": ""} // the todo was reopened
+	}
+	err := timed(r, "update", func() error { // update the todo in mongodb
+		return db.C(collectionName).Update(bson.M{"_id": bson.ObjectIdHex(id)}, update)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating todo", err)
+		return
+	}
+
+	if t.Completed { // record when the todo was completed, unless it already was
+		err = timed(r, "update", func() error {
+			_, err := db.C(collectionName).UpdateAll(
+				bson.M{"_id": bson.ObjectIdHex(id), "completed_at": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"completed_at": time.Now()}},
+			)
+			return err
+		})
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error updating todo", err)
+			return
+		}
+	}
+
+	previews.lookup(r.Context(), t.Title) // start fetching the link previews
+
+	respondMessage(w, http.StatusOK, "Todo updated successfully", nil)
 }
 
-var t todo
+func pinTodo(w http.ResponseWriter, r *http.Request) { // pin todo handler
+	setPinned(w, r, true)
+}
 
-if err := json.NewDecoder(r.Body).Decode(&t); err != nil { // decode the request body to todo struct
-	rnd.JSON(w, http.StatusProcessing, err)
-	return
+func unpinTodo(w http.ResponseWriter, r *http.Request) { // unpin todo handler
+	setPinned(w, r, false)
 }
 
-if t.Title == "" { // check if the title is empty
-	rnd.JSON(w, http.StatusBadRequest, renderer.M{
-		"message": "Title is required",
+func setPinned(w http.ResponseWriter, r *http.Request, pinned bool) { // set the pinned status of a todo
+	id := strings.TrimSpace(chi.URLParam(r, "id")) // get the todo id from the url
+
+	if !bson.IsObjectIdHex(id) { // check if the todo id is valid
+		respondMessage(w, http.StatusBadRequest, "Invalid todo id", nil)
+		return
+	}
+
+	err := timed(r, "update", func() error {
+		return db.C(collectionName).UpdateId(bson.ObjectIdHex(id), bson.M{"$set": bson.M{"pinned": pinned}})
+	})
+	if err == mgo.ErrNotFound { // check if the todo exists
+		respondMessage(w, http.StatusNotFound, "Todo not found", nil)
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error updating todo", err)
+		return
+	}
+
+	respondMessage(w, http.StatusOK, "Todo updated successfully", renderer.M{
+		"pinned": pinned,
 	})
-	return
 }
 
-if err := db.C(collectionName).
-Update(
-	bson.M{"_id": bson.ObjectIdHex(id)}, // query
-	bson.M{"title": t.Title, "completed": t.Completed}, // update
-); err != nil { // update the todo in mongodb
-	rnd.JSON(w, http.StatusProcessing, renderer.M{
-		"message": "Error updating todo",
-		"error":   err,
+func mergeTodos(w http.ResponseWriter, r *http.Request) { // merge todos handler
+	var req struct {
+		IDs []string `json:"ids"` // the first id is the todo that is kept
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { // decode the request body
+		respondError(w, http.StatusBadRequest, "Invalid request body", err)
+		return
+	}
+
+	ids := []bson.ObjectId{}
+	seen := map[string]bool{}
+	for _, id := range req.IDs { // validate the ids and drop the duplicates
+		id = strings.TrimSpace(id)
+		if !bson.IsObjectIdHex(id) {
+			respondMessage(w, http.StatusBadRequest, "Invalid todo id", renderer.M{"id": id})
+			return
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, bson.ObjectIdHex(id))
+		}
+	}
+	if len(ids) < 2 || len(ids) > maxMergeIDs {
+		respondMessage(w, http.StatusBadRequest, fmt.Sprintf("Between 2 and %d todo ids are required", maxMergeIDs), nil)
+		return
+	}
+
+	todos := []todoModel{}
+	err := timed(r, "find", func() error { // fetch the todos to merge
+		return db.C(collectionName).Find(bson.M{"_id": bson.M{"$in": ids}}).SetMaxTime(queryTimeout).All(&todos)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error fetching todos", err)
+		return
+	}
+	if len(todos) != len(ids) {
+		respondMessage(w, http.StatusNotFound, "Todo not found", nil)
+		return
+	}
+
+	survivor := todoModel{ID: ids[0], Completed: true}
+	for _, t := range todos { // the merged todo is done only if all of them are, and pinned if any is
+		if survivor.CreatedAt.IsZero() || t.CreatedAt.Before(survivor.CreatedAt) {
+			survivor.CreatedAt = t.CreatedAt
+		}
+		survivor.Completed = survivor.Completed && t.Completed
+		survivor.Pinned = survivor.Pinned || t.Pinned
+	}
+
+	update := bson.M{"$set": bson.M{
+		"completed":  survivor.Completed,
+		"pinned":     survivor.Pinned,
+		"created_at": survivor.CreatedAt,
+	}}
+	if !survivor.Completed {
+		update["$unset"] = bson.M{"completed_at": ""}
+	}
+	err = timed(r, "update", func() error { // update the kept todo
+		return db.C(collectionName).UpdateId(survivor.ID, update)
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error merging todos", err)
+		return
+	}
+	err = timed(r, "remove", func() error { // remove the merged todos
+		_, err := db.C(collectionName).RemoveAll(bson.M{"_id": bson.M{"$in": ids[1:]}})
+		return err
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "Error merging todos", err)
+		return
+	}
+
+	respondMessage(w, http.StatusOK, "Todos merged successfully", renderer.M{
+		"todo_id": survivor.ID.Hex(),
+		"merged":  len(ids) - 1,
 	})
-	return
-}}
+}
 
 func main() {
-	stopChan := make(chan os.Signal)      // channel to receive os interrupt signal
-	signal.Notify(stopChan, os.Interrupt) // notify the channel when os interrupt signal is received
-	r := chi.NewRouter()                  // initialize the router
-	r.Use(middleware.Logger)              // use the logger middleware
-	r.Get("/", homeHandler)               // handle the home route
-	r.Mount("/todo", todoRouters())       // mount the todo router
-
-	// start the server
+	if len(os.Args) > 1 && os.Args[1] == "check" { // validate the configuration and the dependencies, then exit
+		os.Exit(runCheck(os.Stdout))
+	}
+
+	r := chi.NewRouter()                         // initialize the router
+	r.Use(middleware.RequestID)                  // assign a request id, or keep the one sent in X-Request-Id
+	r.Use(echoRequestID)                         // return the request id to the client
+	r.Use(middleware.Logger)                     // use the logger middleware
+	r.Use(maintenance)                           // reject writes while in maintenance mode
+	r.Get("/", homeHandler)                      // handle the home route
+	r.Get("/static/*", staticHandler)            // serve the static assets
+	r.Get("/metrics", metricsHandler)            // export the prometheus metrics
+	r.Mount("/todo", todoHandlers())             // mount the todo router
+	r.Get("/review", weeklyReview)               // handle the weekly review route
+	r.Get("/stats/trend", fetchTrend)            // handle the trend stats route
+	r.Mount("/imports", importHandlers())        // mount the import router
+	r.Post("/hooks/generic", receiveGenericHook) // turn external payloads into todos
+
 	srv := &http.Server{
 		Addr:         port,              // set the port
 		Handler:      r,                 // set the default handler
@@ -198,38 +412,112 @@ func main() {
 		IdleTimeout:  120 * time.Second, // set the idle timeout
 	}
 
-	//idle is a channel that will receive a value when the server is idle
+	lc := &lifecycle{}                              // subsystems are started in order and stopped in reverse
+	lc.register(dbHook())                           // connect to mongodb before serving requests
+	lc.register(hooksHook())                        // load the generic webhook sources
+	lc.register(assetsHook())                       // load the static assets
+	lc.register(importHook())                       // run the queued imports
+	lc.register(snoozeHook())                       // wake snoozed todos up
+	lc.register(serverHook(srv))                    // start the http server
+	checkErr(lc.run(os.Interrupt, syscall.SIGTERM)) // run until the os interrupt or terminate signal is received
+	log.Println("Server gracefully stopped")
+}
 
-	//start the server in a goroutine
-	go func() {
-		log.Println("Listening on port", port)       // print the listening port
-		if err := srv.ListenAndServe(); err != nil { // start the server
-			log.Printf("listen: %s\n", err) // print the error
-		}
-	}()
+func dbHook() hook { // mongodb lifecycle hook
+	return hook{
+		name:    "mongodb",
+		timeout: 10 * time.Second,
+		start: func(ctx context.Context) error {
+			timeout := 10 * time.Second
+			if deadline, ok := ctx.Deadline(); ok { // leave a margin so the dial error is reported, not the hook timeout
+				timeout = time.Until(deadline) - time.Second
+			}
+			s, err := mgo.DialWithTimeout(hostName, timeout) // connect to mongodb
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil { // the lifecycle gave up waiting, do not publish the session
+				s.Close()
+				return ctx.Err()
+			}
+			s.SetMode(mgo.Monotonic, true) // set the session mode to monotonic
+			sess = s
+			db = sess.DB(dbName)                   // get the database
+			if _, on := maintenanceMessage(); on { // no index builds or collMod during the maintenance window
+				log.Println("Maintenance mode is on, skipping the mongodb indexes and schema until the next start")
+				return nil
+			}
+			if err := ensureIndexes(db); err != nil { // create the indexes used by the queries
+				sess.Close()
+				return err
+			}
+			schema := removeSchema
+			if envBool("STRICT_SCHEMA", false) { // let mongodb reject documents that do not match the model
+				schema = ensureSchema
+			}
+			if err := schema(db); err != nil {
+				sess.Close()
+				return err
+			}
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			sess.Close() // close the mongodb session
+			return nil
+		},
+	}
+}
 
-	<-stopChan                                                              // wait for the os interrupt signal
-	log.Println("Shutting down the server...")                              // print the message
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second) // create a context with timeout
-	srv.Shutdown(ctx)                                                       // shutdown the server
-	defer cancel(
-		log.Println("Server gracefully stopped") 				
-	)
-	}                                                       
+func serverHook(srv *http.Server) hook { // http server lifecycle hook
+	return hook{
+		name: "http server",
+		start: func(ctx context.Context) error {
+			ln, err := listen(srv.Addr) // bind the port so errors are reported on startup
+			if err != nil {
+				return err
+			}
+			go func() {
+				log.Println("Listening on port", port)                               // print the listening port
+				if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed { // serve the requests
+					log.Printf("listen: %s\n", err) // print the error
+				}
+			}()
+			return nil
+		},
+		stop: func(ctx context.Context) error {
+			return srv.Shutdown(ctx) // wait for the in-flight requests to finish
+		},
+	}
+}
 
 func todoHandlers() http.Handler { // todo handlers
 	rg := chi.NewRouter()         // initialize the router
 	rg.Group(func(r chi.Router) { // group the routes
-		r.Get("/", fetchTodos)        // handle the fetch todos route
-		r.Post("/", createTodo)       // handle the create todo route
-		r.Put("/{id}", updateTodo)    // handle the update todo route
-		r.Delete("/{id}", deleteTodo) // handle the delete todo route
+		r.Get("/", fetchTodos)             // handle the fetch todos route
+		r.Post("/", createTodo)            // handle the create todo route
+		r.Post("/merge", mergeTodos)       // handle the merge todos route
+		r.Put("/{id}", updateTodo)         // handle the update todo route
+		r.Delete("/{id}", deleteTodo)      // handle the delete todo route
+		r.Post("/{id}/pin", pinTodo)       // handle the pin todo route
+		r.Delete("/{id}/pin", unpinTodo)   // handle the unpin todo route
+		r.Post("/{id}/snooze", snoozeTodo) // handle the snooze todo route
+		r.Delete("/{id}/snooze", wakeTodo) // handle the wake todo route
+	})
+	return rg // return the router
+}
+
+func importHandlers() http.Handler { // import handlers
+	rg := chi.NewRouter()         // initialize the router
+	rg.Group(func(r chi.Router) { // group the routes
+		r.Post("/", createImport)                // handle the create import route
+		r.Get("/{id}", fetchImport)              // handle the import status route
+		r.Get("/{id}/errors", fetchImportErrors) // handle the import error report route
 	})
 	return rg // return the router
 }
 
 func checkErr(err error) { // check for error
-	if err != nil {       // check if error is not nil then print the error and exit
-		log.Fatal(err)   // print the error
+	if err != nil { // check if error is not nil then print the error and exit
+		log.Fatal(err) // print the error
 	}
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/thedevsaddam/renderer"
+)
+
+// defaultMaintenanceMessage is returned when the flag file is empty
+const defaultMaintenanceMessage = "The service is in maintenance mode, please try again later"
+
+// maintenanceFile is the flag file; while it exists writes are rejected and its content is the message
+var maintenanceFile = envString("MAINTENANCE_FILE", "maintenance.flag")
+
+// maintenanceMessage reports whether maintenance mode is on and the message to return.
+// It is on when MAINTENANCE_MODE=true or the flag file exists, so it can be toggled without a restart.
+func maintenanceMessage() (string, bool) {
+	if b, err := os.ReadFile(maintenanceFile); err == nil {
+		if msg := strings.TrimSpace(string(b)); msg != "" {
+			return msg, true
+		}
+		return defaultMaintenanceMessage, true
+	}
+	if envBool("MAINTENANCE_MODE", false) {
+		return defaultMaintenanceMessage, true
+	}
+	return "", false
+}
+
+// maintenance rejects every write with 503 while maintenance mode is on; reads are still served
+func maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		msg, on := maintenanceMessage()
+		if !on {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", "120")
+		respondMessage(w, http.StatusServiceUnavailable, msg, renderer.M{
+			"maintenance": true,
+		})
+	})
+}
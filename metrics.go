@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// dbLatencyBuckets are the upper bounds in seconds of the database latency histogram
+var dbLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// debugLog enables a log line per database operation
+var debugLog = envBool("DEBUG", false)
+
+type (
+
+	// opKey identifies a database operation made while serving a route
+	opKey struct {
+		route string
+		op    string
+	}
+
+	// histogram is a prometheus style cumulative histogram
+	histogram struct {
+		counts []uint64 // one per bucket, plus +Inf
+		sum    float64
+		count  uint64
+	}
+
+	// dbMetrics holds the database latency histograms
+	dbMetrics struct {
+		mu    sync.Mutex
+		stats map[opKey]*histogram
+	}
+)
+
+var metrics = &dbMetrics{stats: map[opKey]*histogram{}} // database latency metrics
+
+// timed runs a database operation and records its duration under the route of r.
+// r is nil for operations made outside a request, e.g. by background jobs.
+func timed(r *http.Request, op string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	elapsed := time.Since(start)
+
+	route := "background"
+	if r != nil {
+		route = "unmatched" // a raw path would make the label set unbounded
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+	}
+	metrics.observe(opKey{route: route, op: op}, elapsed)
+
+	if debugLog {
+		prefix := ""
+		if r != nil {
+			prefix = logPrefix(r.Context())
+		}
+		log.Printf("%sdb %s %s took %s (err: %v)\n", prefix, op, route, elapsed, err)
+	}
+	return err
+}
+
+// observe records one operation duration
+func (m *dbMetrics) observe(key opKey, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.stats[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(dbLatencyBuckets)+1)}
+		m.stats[key] = h
+	}
+	seconds := d.Seconds()
+	i := sort.SearchFloat64s(dbLatencyBuckets, seconds) // first bucket with bound >= seconds
+	h.counts[i]++
+	h.sum += seconds
+	h.count++
+}
+
+// snapshot copies the histograms, so they can be written out without holding the lock
+func (m *dbMetrics) snapshot() map[opKey]histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := make(map[opKey]histogram, len(m.stats))
+	for k, h := range m.stats {
+		c := *h
+		c.counts = append([]uint64(nil), h.counts...)
+		stats[k] = c
+	}
+	return stats
+}
+
+// metricsHandler exports the metrics in the prometheus text format
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	stats := metrics.snapshot() // a slow scrape must not block the database operations
+
+	keys := make([]opKey, 0, len(stats))
+	for k := range stats {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { // stable output
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		return keys[i].op < keys[j].op
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP todo_db_operation_duration_seconds Duration of the database operations by route and operation.")
+	fmt.Fprintln(w, "# TYPE todo_db_operation_duration_seconds histogram")
+	for _, k := range keys {
+		h := stats[k]
+		labels := fmt.Sprintf("route=%q,op=%q", k.route, k.op)
+		var cumulative uint64
+		for i, bound := range dbLatencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "todo_db_operation_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, cumulative)
+		}
+		fmt.Fprintf(w, "todo_db_operation_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(w, "todo_db_operation_duration_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(w, "todo_db_operation_duration_seconds_count{%s} %d\n", labels, h.count)
+	}
+}
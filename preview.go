@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// constants used by the link previews
+const (
+	previewTTL        time.Duration = 24 * time.Hour  // how long a fetched preview is kept
+	previewFailureTTL time.Duration = time.Hour       // how long a failed fetch is remembered
+	previewTimeout    time.Duration = 5 * time.Second // deadline of one fetch, redirects included
+	previewMaxBody    int64         = 512 << 10       // only the head of the page is read
+	previewMaxEntries int           = 1000            // cache size limit
+	previewWorkers    int           = 4               // concurrent fetches
+	previewMaxPending int           = 32              // fetches started or waiting; more are dropped
+	previewMaxURLs    int           = 3               // urls previewed per todo
+)
+
+var (
+	linkPreviews = envBool("LINK_PREVIEWS", true) // fetch previews of the urls found in todos
+
+	urlPattern   = regexp.MustCompile(`https?://[^\s<>"']+`)
+	metaPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*("[^"]*"|'[^']*')`)
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	errForbiddenAddress = errors.New("address is not allowed")
+
+	// deniedNets are the special-purpose ranges of the IANA registries, never fetched from.
+	// Ipv4-mapped ipv6 addresses are matched against the ipv4 ranges, as net.IP stores them alike.
+	deniedNets = mustParseCIDRs(
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+		"192.0.0.0/24", "192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16", "198.18.0.0/15",
+		"198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4",
+		"::/128", "::1/128", "64:ff9b::/96", "64:ff9b:1::/48", "100::/64", "2001::/23",
+		"2001:db8::/32", "3fff::/20", "5f00::/16", "fc00::/7", "fe80::/10", "fec0::/10", "ff00::/8",
+	)
+	sixToFourNet = mustParseCIDRs("2002::/16")[0] // 6to4, carrying an ipv4 address in bytes 2 to 5
+)
+
+// mustParseCIDRs parses the cidrs, panicking on an invalid one
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+type (
+
+	// linkPreview is the OpenGraph card of a url found in a todo
+	linkPreview struct {
+		URL         string `json:"url"`
+		Title       string `json:"title,omitempty"`
+		Description string `json:"description,omitempty"`
+		Image       string `json:"image,omitempty"`
+		SiteName    string `json:"site_name,omitempty"`
+	}
+
+	// previewEntry is a cached preview; pending entries are being fetched
+	previewEntry struct {
+		preview   *linkPreview // nil when the fetch failed
+		pending   bool
+		expiresAt time.Time
+	}
+
+	// previewCache holds the fetched previews and schedules the missing ones
+	previewCache struct {
+		mu      sync.Mutex
+		entries map[string]*previewEntry
+		pending int           // entries being fetched
+		slots   chan struct{} // limits the concurrent fetches
+		client  *http.Client
+	}
+)
+
+var previews = newPreviewCache() // link preview cache
+
+func newPreviewCache() *previewCache {
+	dialer := &net.Dialer{Timeout: previewTimeout, Control: publicOnly}
+	return &previewCache{
+		entries: map[string]*previewEntry{},
+		slots:   make(chan struct{}, previewWorkers),
+		client: &http.Client{
+			Timeout: previewTimeout,
+			Transport: &http.Transport{
+				Proxy:               nil, // a proxy would bypass the address check
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: previewTimeout,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 3 {
+					return errors.New("too many redirects")
+				}
+				return nil
+			},
+		},
+	}
+}
+
+// publicOnly refuses connections to addresses that are not public and to ports
+// other than 80 and 443. It runs after name resolution, so hostnames resolving
+// to internal addresses are refused too.
+func publicOnly(network, address string, c syscall.RawConn) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || (port != "80" && port != "443") || !publicAddress(ip) {
+		return fmt.Errorf("%s: %w", address, errForbiddenAddress)
+	}
+	return nil
+}
+
+// publicAddress reports whether ip is a global unicast address outside the denied ranges;
+// a 6to4 address is judged by the ipv4 address it carries
+func publicAddress(ip net.IP) bool {
+	if !ip.IsGlobalUnicast() {
+		return false
+	}
+	for _, n := range deniedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if ip.To4() == nil && sixToFourNet.Contains(ip) {
+		return publicAddress(net.IP(ip[2:6]))
+	}
+	return true
+}
+
+// findURLs returns the distinct urls found in text
+func findURLs(text string) []string {
+	urls := []string{}
+	seen := map[string]bool{}
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}") // punctuation ending a sentence
+		if !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// lookup returns the fetched previews of the urls in text and schedules a fetch for the missing ones.
+// The fetches are traced to the request id of ctx.
+func (c *previewCache) lookup(ctx context.Context, text string) []linkPreview {
+	if !linkPreviews {
+		return nil
+	}
+
+	found := []linkPreview{}
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	urls := findURLs(text)
+	if len(urls) > previewMaxURLs {
+		urls = urls[:previewMaxURLs]
+	}
+	for _, u := range urls {
+		e, ok := c.entries[u]
+		if ok && (e.pending || now.Before(e.expiresAt)) {
+			if e.preview != nil {
+				found = append(found, *e.preview)
+			}
+			continue
+		}
+		c.schedule(detach(ctx), u)
+	}
+	return found
+}
+
+// schedule fetches the preview of u in the background; c.mu must be held.
+// Nothing is scheduled while too many fetches are pending or the cache is full of them;
+// a later lookup schedules it again.
+func (c *previewCache) schedule(ctx context.Context, u string) {
+	if c.pending >= previewMaxPending {
+		return
+	}
+	if len(c.entries) >= previewMaxEntries { // make room by dropping the expired entries, or all of them
+		now := time.Now()
+		for k, e := range c.entries {
+			if !e.pending && now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= previewMaxEntries {
+			for k, e := range c.entries {
+				if !e.pending {
+					delete(c.entries, k)
+				}
+			}
+		}
+	}
+	if len(c.entries) >= previewMaxEntries {
+		return
+	}
+	c.entries[u] = &previewEntry{pending: true}
+	c.pending++
+
+	go func() {
+		c.slots <- struct{}{}
+		defer func() { <-c.slots }()
+
+		p, err := c.fetch(ctx, u)
+		entry := &previewEntry{preview: p, expiresAt: time.Now().Add(previewTTL)}
+		if err != nil {
+			log.Printf("%slink preview %s: %s\n", logPrefix(ctx), u, err)
+			entry = &previewEntry{expiresAt: time.Now().Add(previewFailureTTL)}
+		}
+
+		c.mu.Lock()
+		c.entries[u] = entry
+		c.pending--
+		c.mu.Unlock()
+	}()
+}
+
+// fetch downloads the head of the page at u and reads its OpenGraph tags
+func (c *previewCache) fetch(ctx context.Context, u string) (*linkPreview, error) {
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "todo-link-preview/1.0")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" {
+		return nil, fmt.Errorf("unexpected content type %q", mediaType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, previewMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	return parsePreview(u, resp.Request.URL, string(body)), nil
+}
+
+// parsePreview reads the OpenGraph meta tags of page, falling back to its title
+func parsePreview(u string, base *url.URL, page string) *linkPreview {
+	p := &linkPreview{URL: u}
+
+	for _, tag := range metaPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = html.UnescapeString(strings.TrimSpace(m[2][1 : len(m[2])-1]))
+		}
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		content := attrs["content"]
+
+		switch strings.ToLower(key) {
+		case "og:title":
+			p.Title = content
+		case "og:description":
+			p.Description = content
+		case "description":
+			if p.Description == "" {
+				p.Description = content
+			}
+		case "og:site_name":
+			p.SiteName = content
+		case "og:image":
+			if img, err := base.Parse(content); err == nil && (img.Scheme == "http" || img.Scheme == "https") {
+				p.Image = img.String()
+			}
+		}
+	}
+
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			p.Title = html.UnescapeString(strings.TrimSpace(m[1]))
+		}
+	}
+	return p
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFindURLs(t *testing.T) {
+	got := findURLs("read https://example.com/a. then (http://example.org/b) and https://example.com/a again")
+	want := []string{"https://example.com/a", "http://example.org/b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findURLs = %q, want %q", got, want)
+	}
+}
+
+func TestParsePreview(t *testing.T) {
+	base, _ := url.Parse("https://example.com/posts/1")
+	page := `<html><head>
+		<title>Page &amp; title</title>
+		<meta property="og:title" content="OG &quot;title&quot;">
+		<META name='description' content='A description'>
+		<meta property="og:site_name" content="Example">
+		<meta property="og:image" content="/img/cover.png">
+	</head></html>`
+
+	got := parsePreview("https://example.com/posts/1", base, page)
+	want := &linkPreview{
+		URL:         "https://example.com/posts/1",
+		Title:       `OG "title"`,
+		Description: "A description",
+		Image:       "https://example.com/img/cover.png",
+		SiteName:    "Example",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePreview = %+v, want %+v", got, want)
+	}
+
+	got = parsePreview("u", base, `<title> Fallback </title><meta property="og:image" content="javascript:alert(1)">`)
+	if got.Title != "Fallback" || got.Image != "" {
+		t.Errorf("parsePreview fallback = %+v, want the page title and no image", got)
+	}
+}
+
+func TestPublicOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"93.184.216.34:80", true},
+		{"93.184.216.34:8080", false},
+		{"127.0.0.1:80", false},
+		{"10.1.2.3:443", false},
+		{"192.168.0.1:80", false},
+		{"169.254.169.254:80", false}, // cloud metadata
+		{"0.0.0.0:80", false},
+		{"0.1.2.3:80", false},
+		{"100.100.100.200:80", false}, // cgnat, alibaba cloud metadata
+		{"172.16.0.1:443", false},
+		{"192.0.0.170:443", false},
+		{"198.18.0.1:443", false},
+		{"203.0.113.7:443", false},
+		{"240.0.0.1:443", false},
+		{"255.255.255.255:80", false},
+		{"224.0.0.1:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"[::ffff:93.184.216.34]:443", true},
+		{"[64:ff9b::a9fe:a9fe]:80", false}, // nat64 of 169.254.169.254
+		{"[2002:a00:1::1]:443", false},     // 6to4 carrying 10.0.0.1
+		{"[2002:5db8:d822::1]:443", true},  // 6to4 carrying 93.184.216.34
+		{"[2001:db8::1]:443", false},
+		{"[fe80::1]:443", false},
+		{"[ff02::1]:443", false},
+		{"[::]:443", false},
+		{"[::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"[2606:2800:220:1::1]:443", true},
+	}
+
+	for _, tt := range tests {
+		err := publicOnly("tcp", tt.address, nil)
+		if tt.allowed && err != nil {
+			t.Errorf("publicOnly(%s) = %v, want allowed", tt.address, err)
+		}
+		if !tt.allowed && !errors.Is(err, errForbiddenAddress) {
+			t.Errorf("publicOnly(%s) = %v, want %v", tt.address, err, errForbiddenAddress)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+
+	// relativeDates are the humanized timestamps of a todo, e.g. "3 hours ago"
+	relativeDates struct {
+		CreatedAt    string `json:"created_at"`
+		CompletedAt  string `json:"completed_at,omitempty"`
+		SnoozedUntil string `json:"snoozed_until,omitempty"`
+	}
+
+	// phrases are the words of one language
+	phrases struct {
+		now       string
+		ago       string // format of a past amount, e.g. "%s ago"
+		in        string // format of a future amount, e.g. "in %s"
+		yesterday string
+		tomorrow  string
+		units     map[string][2]string // unit -> singular, plural formats
+	}
+
+	// humanizer renders timestamps relative to now in a language and timezone
+	humanizer struct {
+		now  time.Time
+		loc  *time.Location
+		lang phrases
+	}
+)
+
+// languages are the supported Accept-Language tags
+var languages = map[string]phrases{
+	"en": {
+		now: "just now", ago: "%s ago", in: "in %s", yesterday: "yesterday", tomorrow: "tomorrow",
+		units: map[string][2]string{
+			"minute": {"1 minute", "%d minutes"},
+			"hour":   {"1 hour", "%d hours"},
+			"day":    {"1 day", "%d days"},
+			"month":  {"1 month", "%d months"},
+			"year":   {"1 year", "%d years"},
+		},
+	},
+	"th": {
+		now: "เมื่อสักครู่", ago: "%sที่แล้ว", in: "อีก %s", yesterday: "เมื่อวาน", tomorrow: "พรุ่งนี้",
+		units: map[string][2]string{
+			"minute": {"1 นาที", "%d นาที"},
+			"hour":   {"1 ชั่วโมง", "%d ชั่วโมง"},
+			"day":    {"1 วัน", "%d วัน"},
+			"month":  {"1 เดือน", "%d เดือน"},
+			"year":   {"1 ปี", "%d ปี"},
+		},
+	},
+}
+
+// newHumanizer returns the humanizer asked for with ?relative_dates=true, or nil when it was not asked for.
+// The language comes from Accept-Language and the timezone from ?tz=, defaulting to english and UTC.
+func newHumanizer(r *http.Request) (*humanizer, error) {
+	if on, _ := strconv.ParseBool(r.URL.Query().Get("relative_dates")); !on {
+		return nil, nil
+	}
+
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", tz)
+		}
+		loc = l
+	}
+	return &humanizer{now: time.Now(), loc: loc, lang: languages[acceptedLanguage(r)]}, nil
+}
+
+// acceptedLanguage returns the supported language of the Accept-Language header with the highest quality
+func acceptedLanguage(r *http.Request) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	tags := []weighted{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		fields := strings.Split(part, ";")
+		w := weighted{tag: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				q, err := strconv.ParseFloat(v[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				w.q = q
+			}
+		}
+		if w.q > 0 { // q=0 means not acceptable
+			tags = append(tags, w)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q }) // header order breaks ties
+
+	for _, w := range tags {
+		if _, ok := languages[w.tag]; ok {
+			return w.tag
+		}
+		if i := strings.Index(w.tag, "-"); i > 0 { // e.g. en-US
+			if _, ok := languages[w.tag[:i]]; ok {
+				return w.tag[:i]
+			}
+		}
+	}
+	return "en"
+}
+
+// amount formats n units
+func (h *humanizer) amount(n int, unit string) string {
+	f := h.lang.units[unit]
+	if n == 1 {
+		return f[0]
+	}
+	return fmt.Sprintf(f[1], n)
+}
+
+// format renders t relative to now, e.g. "3 hours ago", "yesterday" or "in 2 days"
+func (h *humanizer) format(t time.Time) string {
+	d := t.Sub(h.now)
+	past := d < 0
+	if past {
+		d = -d
+	}
+
+	var s string
+	switch {
+	case d < 45*time.Second:
+		return h.lang.now
+	case d < 45*time.Minute:
+		s = h.amount(int((d+30*time.Second)/time.Minute), "minute")
+	case d < 22*time.Hour:
+		s = h.amount(int((d+30*time.Minute)/time.Hour), "hour")
+	default: // whole days are counted on the calendar of the user's timezone
+		days := calendarDays(h.now.In(h.loc), t.In(h.loc))
+		if days < 0 {
+			days = -days
+		}
+		switch {
+		case days == 0: // still the same day for the user
+			s = h.amount(int((d+30*time.Minute)/time.Hour), "hour")
+		case days == 1 && past:
+			return h.lang.yesterday
+		case days == 1:
+			return h.lang.tomorrow
+		case days < 30:
+			s = h.amount(days, "day")
+		case days < 365:
+			s = h.amount(days/30, "month")
+		default:
+			s = h.amount(days/365, "year")
+		}
+	}
+
+	if past {
+		return fmt.Sprintf(h.lang.ago, s)
+	}
+	return fmt.Sprintf(h.lang.in, s)
+}
+
+// calendarDays returns the number of calendar days from a to b, both in the same location
+func calendarDays(a, b time.Time) int {
+	from := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	to := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(to.Sub(from) / (24 * time.Hour))
+}
+
+// addRelativeDates sets the humanized timestamps of todos when h is not nil
+func (h *humanizer) addRelativeDates(todos []todo) {
+	if h == nil {
+		return
+	}
+	for i := range todos {
+		t := &todos[i]
+		rel := &relativeDates{CreatedAt: h.format(t.CreatedAt)}
+		if t.CompletedAt != nil {
+			rel.CompletedAt = h.format(*t.CompletedAt)
+		}
+		if t.SnoozedUntil != nil {
+			rel.SnoozedUntil = h.format(*t.SnoozedUntil)
+		}
+		t.Relative = rel
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHumanizerFormat(t *testing.T) {
+	now := time.Date(2025, time.May, 12, 12, 0, 0, 0, time.UTC)
+	ict := time.FixedZone("ICT", 7*60*60)
+	lateICT := time.Date(2025, time.May, 12, 23, 30, 0, 0, ict) // 16:30 UTC
+
+	tests := []struct {
+		lang string
+		now  time.Time
+		loc  *time.Location
+		t    time.Time
+		want string
+	}{
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(-10 * time.Second), want: "just now"},
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(-time.Minute), want: "1 minute ago"},
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(-3 * time.Hour), want: "3 hours ago"},
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(90 * time.Minute), want: "in 2 hours"},
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(-30 * time.Hour), want: "yesterday"},
+		{lang: "en", now: now, loc: time.UTC, t: now.Add(30 * time.Hour), want: "tomorrow"},
+		{lang: "en", now: lateICT, loc: ict, t: lateICT.Add(-23 * time.Hour), want: "23 hours ago"}, // same day in ICT
+		{lang: "en", now: lateICT, loc: time.UTC, t: lateICT.Add(-23 * time.Hour), want: "yesterday"},
+		{lang: "en", now: now, loc: time.UTC, t: now.AddDate(0, 0, -5), want: "5 days ago"},
+		{lang: "en", now: now, loc: time.UTC, t: now.AddDate(0, 0, -65), want: "2 months ago"},
+		{lang: "en", now: now, loc: time.UTC, t: now.AddDate(0, 0, -400), want: "1 year ago"},
+		{lang: "th", now: now, loc: time.UTC, t: now.Add(-3 * time.Hour), want: "3 ชั่วโมงที่แล้ว"},
+		{lang: "th", now: now, loc: time.UTC, t: now.Add(-30 * time.Hour), want: "เมื่อวาน"},
+		{lang: "th", now: now, loc: time.UTC, t: now.Add(30 * time.Hour), want: "พรุ่งนี้"},
+		{lang: "th", now: now, loc: time.UTC, t: now.AddDate(0, 0, 5), want: "อีก 5 วัน"},
+	}
+
+	for _, tt := range tests {
+		h := &humanizer{now: tt.now, loc: tt.loc, lang: languages[tt.lang]}
+		if got := h.format(tt.t); got != tt.want {
+			t.Errorf("%s format(%s) in %s = %q, want %q", tt.lang, tt.t, tt.loc, got, tt.want)
+		}
+	}
+}
+
+func TestCalendarDays(t *testing.T) {
+	tests := []struct {
+		a, b time.Time
+		want int
+	}{
+		{a: time.Date(2025, time.May, 12, 0, 1, 0, 0, time.UTC), b: time.Date(2025, time.May, 12, 23, 59, 0, 0, time.UTC), want: 0},
+		{a: time.Date(2025, time.May, 12, 23, 59, 0, 0, time.UTC), b: time.Date(2025, time.May, 13, 0, 1, 0, 0, time.UTC), want: 1},
+		{a: time.Date(2025, time.May, 13, 0, 1, 0, 0, time.UTC), b: time.Date(2025, time.May, 12, 23, 59, 0, 0, time.UTC), want: -1},
+		{a: time.Date(2024, time.December, 31, 12, 0, 0, 0, time.UTC), b: time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC), want: 1},
+		{a: time.Date(2024, time.February, 28, 12, 0, 0, 0, time.UTC), b: time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC), want: 2},
+	}
+
+	for _, tt := range tests {
+		if got := calendarDays(tt.a, tt.b); got != tt.want {
+			t.Errorf("calendarDays(%s, %s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAcceptedLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: "en"},
+		{header: "th", want: "th"},
+		{header: "th-TH, en;q=0.5", want: "th"},
+		{header: "th;q=0.1, en;q=0.9", want: "en"},
+		{header: "en;q=0.2, th;q=0.8", want: "th"},
+		{header: "fr, th;q=0.3, en;q=0.2", want: "th"},
+		{header: "th;q=0, en;q=0.1", want: "en"},
+		{header: "th;q=0", want: "en"},
+		{header: "de, fr", want: "en"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/todo", nil)
+		r.Header.Set("Accept-Language", tt.header)
+		if got := acceptedLanguage(r); got != tt.want {
+			t.Errorf("acceptedLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/thedevsaddam/renderer"
+)
+
+// response envelope shapes for data payloads
+const (
+	envelopeData = "data" // {"data": [...], ...}
+	envelopeBare = "bare" // [...]
+)
+
+// defaultEnvelope is the envelope used when the client does not ask for one
+var defaultEnvelope = envString("RESPONSE_ENVELOPE", envelopeData)
+
+// envelope returns the envelope requested with an Accept profile,
+// e.g. "Accept: application/json; profile=bare", or the default one
+func envelope(r *http.Request) string {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (mediaType != "application/json" && mediaType != "*/*") {
+			continue
+		}
+		switch p := params["profile"]; p {
+		case envelopeData, envelopeBare:
+			return p
+		}
+	}
+	return defaultEnvelope
+}
+
+// respondData writes a data payload in the envelope the client asked for.
+// meta is merged into the envelope; bare responses carry no meta.
+func respondData(w http.ResponseWriter, r *http.Request, status int, data interface{}, meta renderer.M) {
+	if envelope(r) == envelopeBare {
+		rnd.JSON(w, status, data)
+		return
+	}
+
+	body := renderer.M{"data": data}
+	for k, v := range meta {
+		body[k] = v
+	}
+	rnd.JSON(w, status, body)
+}
+
+// respondMessage writes {"message": msg} along with the extra fields
+func respondMessage(w http.ResponseWriter, status int, msg string, fields renderer.M) {
+	body := renderer.M{"message": msg}
+	for k, v := range fields {
+		body[k] = v
+	}
+	rnd.JSON(w, status, body)
+}
+
+// respondError writes {"message": msg, "error": err}; errors are rendered as their text.
+// Informational 1xx statuses would reach the client as 200, so they are sent as 500.
+func respondError(w http.ResponseWriter, status int, msg string, err error) {
+	if status < 200 {
+		status = http.StatusInternalServerError
+	}
+	body := renderer.M{"message": msg}
+	if err != nil {
+		body["error"] = err.Error()
+	}
+	rnd.JSON(w, status, body)
+}
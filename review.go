@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// isoWeekPattern matches an ISO week such as "2025-W20"
+var isoWeekPattern = regexp.MustCompile(`^(\d{4})-W(\d{2})$`)
+
+type (
+
+	// weekReview is the GTD style summary of one ISO week
+	weekReview struct {
+		Week        string      `json:"week"`
+		Start       time.Time   `json:"start"`
+		End         time.Time   `json:"end"`
+		Completed   []todo      `json:"completed"`    // completed during the week
+		CarriedOver []todo      `json:"carried_over"` // open before the week and still open at its end
+		Created     []todo      `json:"created"`      // created during the week
+		Throughput  weekSummary `json:"throughput"`
+		Truncated   bool        `json:"truncated"` // a section lists only its first MAX_PER_PAGE todos
+	}
+
+	// weekSummary counts the todos of a week review
+	weekSummary struct {
+		Completed   int `json:"completed"`
+		CarriedOver int `json:"carried_over"`
+		Created     int `json:"created"`
+	}
+)
+
+// parseISOWeek returns the start (monday 00:00 UTC) of an ISO week such as "2025-W20"
+func parseISOWeek(s string) (time.Time, error) {
+	m := isoWeekPattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid week %q, expected YYYY-Www", s)
+	}
+	year, _ := strconv.Atoi(m[1])
+	week, _ := strconv.Atoi(m[2])
+
+	jan4 := time.Date(year, time.January, 4, 0, 0, 0, 0, time.UTC) // january 4th is always in week 1
+	start := jan4.AddDate(0, 0, -((int(jan4.Weekday())+6)%7)+(week-1)*7)
+	if y, w := start.ISOWeek(); y != year || w != week { // e.g. week 53 of a 52 week year
+		return time.Time{}, fmt.Errorf("invalid week %q", s)
+	}
+	return start, nil
+}
+
+// isoWeek formats t as an ISO week such as "2025-W20"
+func isoWeek(t time.Time) string {
+	y, w := t.ISOWeek()
+	return fmt.Sprintf("%04d-W%02d", y, w)
+}
+
+func weeklyReview(w http.ResponseWriter, r *http.Request) { // weekly review handler
+	week := r.URL.Query().Get("week")
+	if week == "" { // default to the current week
+		week = isoWeek(time.Now().UTC())
+	}
+	start, err := parseISOWeek(week)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid week", err)
+		return
+	}
+	end := start.AddDate(0, 0, 7)
+
+	h, err := newHumanizer(r) // humanize the dates when asked to
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "Invalid relative dates options", err)
+		return
+	}
+
+	truncated := false
+	queries := []struct {
+		into  *[]todoModel
+		total *int
+		query bson.M
+	}{
+		{new([]todoModel), new(int), bson.M{"completed_at": bson.M{"$gte": start, "$lt": end}}},
+		{new([]todoModel), new(int), bson.M{"created_at": bson.M{"$lt": start}, "$or": []bson.M{
+			{"completed": false},
+			{"completed_at": bson.M{"$gte": end}},
+		}}},
+		{new([]todoModel), new(int), bson.M{"created_at": bson.M{"$gte": start, "$lt": end}}},
+	}
+	for _, q := range queries { // fetch the first todos of each section
+		err := timed(r, "find", func() error {
+			return db.C(collectionName).Find(q.query).Sort("created_at").
+				Limit(maxPerPage + 1). // one more to know if the section is cut off
+				SetMaxTime(queryTimeout).All(q.into)
+		})
+		*q.total = len(*q.into)
+		if err == nil && *q.total > maxPerPage { // count the whole section for the throughput
+			truncated = true
+			*q.into = (*q.into)[:maxPerPage]
+			err = timed(r, "count", func() (err error) {
+				*q.total, err = count(collectionName, q.query)
+				return err
+			})
+		}
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error fetching todos", err)
+			return
+		}
+	}
+
+	review := weekReview{
+		Week:        week,
+		Start:       start,
+		End:         end,
+		Completed:   toTodos(r.Context(), *queries[0].into),
+		CarriedOver: toTodos(r.Context(), *queries[1].into),
+		Created:     toTodos(r.Context(), *queries[2].into),
+		Truncated:   truncated,
+	}
+	h.addRelativeDates(review.Completed)
+	h.addRelativeDates(review.CarriedOver)
+	h.addRelativeDates(review.Created)
+	review.Throughput = weekSummary{
+		Completed:   *queries[0].total,
+		CarriedOver: *queries[1].total,
+		Created:     *queries[2].total,
+	}
+
+	respondData(w, r, http.StatusOK, review, nil) // return the review
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISOWeek(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		{in: "2025-W20", want: time.Date(2025, time.May, 12, 0, 0, 0, 0, time.UTC)},
+		{in: "2025-W01", want: time.Date(2024, time.December, 30, 0, 0, 0, 0, time.UTC)},
+		{in: "2020-W53", want: time.Date(2020, time.December, 28, 0, 0, 0, 0, time.UTC)},
+		{in: "2021-W53", wantErr: true}, // 2021 has 52 weeks
+		{in: "2025-W00", wantErr: true},
+		{in: "2025-W5x", wantErr: true},
+		{in: "2025-W1", wantErr: true},
+		{in: "2025-W200", wantErr: true},
+		{in: " 2025-W20", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseISOWeek(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseISOWeek(%q) = %s, want an error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseISOWeek(%q) = %s, %v, want %s", tt.in, got, err, tt.want)
+		}
+		if w := isoWeek(got); w != tt.in {
+			t.Errorf("isoWeek(%s) = %q, want %q", got, w, tt.in)
+		}
+	}
+}
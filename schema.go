@@ -0,0 +1,104 @@
+package main
+
+import (
+	"log"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// todoSchema is the $jsonSchema validator matching todoModel; keep it in sync with the model
+func todoSchema() bson.M {
+	return bson.M{
+		"$jsonSchema": bson.M{
+			"bsonType": "object",
+			"required": []string{"_id", "title", "completed", "created_at"},
+			"properties": bson.M{
+				"_id":           bson.M{"bsonType": "objectId"},
+				"title":         bson.M{"bsonType": "string", "minLength": 1},
+				"completed":     bson.M{"bsonType": "bool"},
+				"completed_at":  bson.M{"bsonType": "date"},
+				"pinned":        bson.M{"bsonType": "bool"},
+				"snoozed_until": bson.M{"bsonType": "date"},
+				"created_at":    bson.M{"bsonType": "date"},
+			},
+		},
+	}
+}
+
+// ensureSchema creates the todo collection with the validator, or updates the
+// validator of an existing collection, so writes that do not match the model are rejected
+func ensureSchema(db *mgo.Database) error {
+	names, err := db.CollectionNames()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == collectionName { // the collection exists, replace its validator
+			return db.Run(bson.D{
+				{Name: "collMod", Value: collectionName},
+				{Name: "validator", Value: todoSchema()},
+				{Name: "validationLevel", Value: "strict"},
+				{Name: "validationAction", Value: "error"},
+			}, nil)
+		}
+	}
+
+	return db.C(collectionName).Create(&mgo.CollectionInfo{
+		Validator:        todoSchema(),
+		ValidationLevel:  "strict",
+		ValidationAction: "error",
+	})
+}
+
+// removeSchema drops the validator installed by ensureSchema, so turning STRICT_SCHEMA off takes effect
+func removeSchema(db *mgo.Database) error {
+	installed, err := hasValidator(db)
+	if err != nil || !installed {
+		return err
+	}
+	log.Printf("STRICT_SCHEMA is off, removing the validator of collection %s\n", collectionName)
+	return db.Run(bson.D{
+		{Name: "collMod", Value: collectionName},
+		{Name: "validator", Value: bson.M{}},
+	}, nil)
+}
+
+// todoIndexes are the indexes used by the todo queries
+var todoIndexes = []mgo.Index{
+	{Key: []string{"-pinned", "created_at"}, Background: true}, // todo list order
+	{Key: []string{"completed_at"}, Background: true},          // weekly review and trend
+	{Key: []string{"snoozed_until"}, Background: true},         // snooze filter and waker
+}
+
+// ensureIndexes creates the missing todo indexes
+func ensureIndexes(db *mgo.Database) error {
+	for _, index := range todoIndexes {
+		if err := db.C(collectionName).EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasValidator reports whether the todo collection has a validator
+func hasValidator(db *mgo.Database) (bool, error) {
+	var result struct {
+		Cursor struct {
+			FirstBatch []struct {
+				Options struct {
+					Validator bson.M `bson:"validator"`
+				} `bson:"options"`
+			} `bson:"firstBatch"`
+		} `bson:"cursor"`
+	}
+	err := db.Run(bson.D{
+		{Name: "listCollections", Value: 1},
+		{Name: "filter", Value: bson.M{"name": collectionName}},
+	}, &result)
+	if err != nil {
+		return false, err
+	}
+	batch := result.Cursor.FirstBatch
+	return len(batch) == 1 && len(batch[0].Options.Validator) > 0, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// constants used by the trend endpoint
+const (
+	dayLayout     string        = "2006-01-02"
+	maxTrendDays  int           = 366              // longest range served at once
+	trendHorizon  int           = 3 * 366          // oldest day served, in days before today; bounds the cache
+	trendCacheTTL time.Duration = 10 * time.Minute // past days are recomputed after this, to catch edits and deletes
+)
+
+type (
+
+	// trendPoint is the number of open and closed todos at the end of a day
+	trendPoint struct {
+		Date   string `json:"date"`
+		Open   int    `json:"open"`
+		Closed int    `json:"closed"`
+	}
+
+	// cachedPoint is a trend point of a past day with the time it was computed
+	cachedPoint struct {
+		point      trendPoint
+		computedAt time.Time
+	}
+
+	// trendCache keeps the points of past days, which only change when old todos are edited or deleted
+	trendCache struct {
+		mu     sync.Mutex
+		points map[string]cachedPoint
+	}
+)
+
+var trends = &trendCache{points: map[string]cachedPoint{}} // cached trend points
+
+// get returns the cached point of day, or false when it is missing or stale; stale points are dropped
+func (c *trendCache) get(day time.Time, now time.Time) (trendPoint, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := day.Format(dayLayout)
+	p, ok := c.points[key]
+	if !ok {
+		return trendPoint{}, false
+	}
+	if now.Sub(p.computedAt) > trendCacheTTL {
+		delete(c.points, key)
+		return trendPoint{}, false
+	}
+	return p.point, true
+}
+
+// put caches points, which must all be of days that are over, and drops the stale points of the other days
+func (c *trendCache) put(points []trendPoint, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, p := range c.points {
+		if now.Sub(p.computedAt) > trendCacheTTL {
+			delete(c.points, key)
+		}
+	}
+	for _, p := range points {
+		c.points[p.Date] = cachedPoint{point: p, computedAt: now}
+	}
+}
+
+// computeTrend counts the open and closed todos at the end of each day in one pass over the todos
+func computeTrend(r *http.Request, days []time.Time) ([]trendPoint, error) {
+	first := days[0]
+	end := days[len(days)-1].AddDate(0, 0, 1)
+
+	todos := []todoModel{}
+	err := timed(r, "find", func() error {
+		return db.C(collectionName).
+			Find(bson.M{"created_at": bson.M{"$lt": end}}).
+			Select(bson.M{"created_at": 1, "completed": 1, "completed_at": 1}).
+			SetMaxTime(queryTimeout).
+			All(&todos)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// index of the day an event falls on, events before the range count from the first day
+	dayIndex := func(t time.Time) int {
+		if t.Before(first) {
+			return 0
+		}
+		return int(t.Sub(first) / (24 * time.Hour))
+	}
+
+	opened := make([]int, len(days)+1) // todos created on each day
+	closed := make([]int, len(days)+1) // todos completed on each day
+	for _, t := range todos {
+		opened[dayIndex(t.CreatedAt)]++
+		switch {
+		case t.CompletedAt != nil:
+			if t.CompletedAt.Before(end) {
+				closed[dayIndex(*t.CompletedAt)]++
+			}
+		case t.Completed: // completed before completion times were recorded
+			closed[dayIndex(t.CreatedAt)]++
+		}
+	}
+
+	points := make([]trendPoint, len(days))
+	created, done := 0, 0
+	for i, d := range days { // running totals at the end of each day
+		created += opened[i]
+		done += closed[i]
+		points[i] = trendPoint{Date: d.Format(dayLayout), Open: created - done, Closed: done}
+	}
+	return points, nil
+}
+
+// todayTrend counts today from the end of yesterday totals and the todos created or completed since midnight
+func todayTrend(r *http.Request, today, now time.Time) (trendPoint, error) {
+	yesterday := today.AddDate(0, 0, -1)
+	prev, ok := trends.get(yesterday, now)
+	if !ok {
+		points, err := computeTrend(r, []time.Time{yesterday})
+		if err != nil {
+			return trendPoint{}, err
+		}
+		trends.put(points, now)
+		prev = points[0]
+	}
+
+	var created, completed, legacy int // legacy: completed without a completion time, counted as closed on creation
+	counts := []struct {
+		into  *int
+		query bson.M
+	}{
+		{&created, bson.M{"created_at": bson.M{"$gte": today}}},
+		{&completed, bson.M{"completed_at": bson.M{"$gte": today}}},
+		{&legacy, bson.M{"created_at": bson.M{"$gte": today}, "completed": true, "completed_at": bson.M{"$exists": false}}},
+	}
+	for _, c := range counts {
+		err := timed(r, "count", func() (err error) {
+			*c.into, err = count(collectionName, c.query)
+			return err
+		})
+		if err != nil {
+			return trendPoint{}, err
+		}
+	}
+
+	return trendPoint{
+		Date:   today.Format(dayLayout),
+		Open:   prev.Open + created - completed - legacy,
+		Closed: prev.Closed + completed + legacy,
+	}, nil
+}
+
+func fetchTrend(w http.ResponseWriter, r *http.Request) { // trend stats handler
+	now := time.Now().UTC()
+	today := now.Truncate(24 * time.Hour)
+
+	to, from := today, today.AddDate(0, 0, -29) // default to the last 30 days
+	for name, day := range map[string]*time.Time{"from": &from, "to": &to} {
+		if v := r.URL.Query().Get(name); v != "" {
+			t, err := time.Parse(dayLayout, v)
+			if err != nil {
+				respondError(w, http.StatusBadRequest, "Invalid "+name+" date", err)
+				return
+			}
+			*day = t
+		}
+	}
+	if oldest := today.AddDate(0, 0, -trendHorizon); from.Before(oldest) { // older days are not served
+		from = oldest
+	}
+	if to.Before(from) || to.After(today) || to.Sub(from) >= time.Duration(maxTrendDays)*24*time.Hour {
+		respondMessage(w, http.StatusBadRequest, "Invalid date range", nil)
+		return
+	}
+
+	days := []time.Time{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+
+	points := make([]trendPoint, len(days))
+	missing := []int{} // past days that are not cached
+	for i, d := range days {
+		if !d.Before(today) { // today is counted below
+			continue
+		}
+		if p, ok := trends.get(d, now); ok {
+			points[i] = p
+		} else {
+			missing = append(missing, i)
+		}
+	}
+
+	if len(missing) > 0 { // compute the span of the missing days in one pass
+		first, last := missing[0], missing[len(missing)-1]
+		computed, err := computeTrend(r, days[first:last+1])
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error computing trend", err)
+			return
+		}
+		trends.put(computed, now)
+		copy(points[first:], computed)
+	}
+
+	if last := len(days) - 1; days[last].Equal(today) {
+		p, err := todayTrend(r, today, now)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "Error computing trend", err)
+			return
+		}
+		points[last] = p
+	}
+
+	respondData(w, r, http.StatusOK, points, nil) // return the daily points
+}
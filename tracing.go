@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/middleware"
+)
+
+// requestIDHeader carries the request id back to the client
+const requestIDHeader = "X-Request-Id"
+
+// echoRequestID returns the request id set by middleware.RequestID to the client
+func echoRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(requestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// detach returns a context for async work started by a request: it keeps the
+// request id but is not cancelled when the request ends
+func detach(ctx context.Context) context.Context {
+	return context.WithValue(context.Background(), middleware.RequestIDKey, middleware.GetReqID(ctx))
+}
+
+// logPrefix returns "[request id] " for the log lines of work traced to a request
+func logPrefix(ctx context.Context) string {
+	if id := middleware.GetReqID(ctx); id != "" {
+		return "[" + id + "] "
+	}
+	return ""
+}